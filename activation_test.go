@@ -0,0 +1,66 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivateProjection(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+
+	err = es.ActivateProjection(ctx, projectionID)
+	assert.ErrorIs(t, err, esui.ErrInvalidProjection)
+	assert.ErrorContains(t, err, "no table")
+
+	require.NoError(t, es.CreateTable(ctx, projectionID, "products"))
+	// AddColumn rejects untyped columns, older projections may still have
+	// them.
+	require.NoError(t, estore.StoreEvent(ctx, string(projectionID), "projection", "column_added", esui.EsuiColumnAdded{
+		TableName:  "products",
+		ColumnName: "id",
+	}, esui.AnyVersion))
+	broken := "table(\"products\").insert({"
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "b1", Type: "javascript", Data: esui.BlockData{Javascript: &broken}}))
+
+	err = es.ActivateProjection(ctx, projectionID)
+	assert.ErrorIs(t, err, esui.ErrInvalidProjection)
+	assert.ErrorContains(t, err, "column products.id: invalid column type")
+	assert.ErrorContains(t, err, "block b1")
+
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "b2", OrderedAfter: "b1", Type: "sql", Data: esui.BlockData{Javascript: &broken}}))
+	err = es.ActivateProjection(ctx, projectionID)
+	assert.ErrorIs(t, err, esui.ErrBlockType)
+	require.NoError(t, es.RemoveBlock(ctx, projectionID, "b2"))
+
+	assert.ErrorIs(t, es.AddColumn(ctx, projectionID, "products", "id", "string"), esui.ErrColumnExists)
+	require.NoError(t, es.ChangeColumnType(ctx, projectionID, "products", "id", esui.ColumnString))
+	script := "if (!event) return;\ntable(\"products\").insert({id: event.aggregate_id});"
+	require.NoError(t, es.UpdateBlock(ctx, projectionID, esui.Block{BlockID: "b1", Type: "javascript", Data: esui.BlockData{Javascript: &script}}))
+
+	assert.ErrorIs(t, es.DeactivateProjection(ctx, projectionID), esui.ErrNotActive)
+	require.NoError(t, es.ActivateProjection(ctx, projectionID))
+	assert.ErrorIs(t, es.ActivateProjection(ctx, projectionID), esui.ErrAlreadyActive)
+	assert.ErrorIs(t, es.RemoveBlock(ctx, projectionID, "b1"), esui.ErrProjectionActive)
+	assert.ErrorIs(t, es.DropTable(ctx, projectionID, "products"), esui.ErrProjectionActive)
+
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	assert.True(t, projection.IsActive)
+
+	require.NoError(t, es.DeactivateProjection(ctx, projectionID))
+	projection, err = es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	assert.False(t, projection.IsActive)
+}
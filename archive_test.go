@@ -0,0 +1,81 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveEntity(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("user1").Once()
+	entityID, err := es.CreateEntity(ctx, "user")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_created"))
+
+	assert.ErrorIs(t, es.RestoreEntity(ctx, entityID), esui.ErrNotArchived)
+	require.NoError(t, es.ArchiveEntity(ctx, entityID))
+	assert.ErrorIs(t, es.ArchiveEntity(ctx, entityID), esui.ErrArchived)
+
+	entity, err := es.GetEntity(ctx, entityID)
+	require.NoError(t, err)
+	assert.True(t, entity.Archived)
+	assert.Contains(t, entity.Events, "user_created")
+
+	assert.ErrorIs(t, es.AddEventToEntity(ctx, entityID, "user_deleted"), esui.ErrArchived)
+	assert.ErrorIs(t, es.AddAttribute(ctx, entityID, "user_created", "name", esui.TypeString), esui.ErrArchived)
+	assert.ErrorIs(t, es.RenameEntity(ctx, entityID, "member"), esui.ErrArchived)
+
+	catalog := esui.NewCatalog()
+	subscription := esui.NewSubscription("catalog", estore, eventstore.NewMemoryCheckpoints(), catalog.Handle)
+	require.NoError(t, subscription.CatchUp(ctx))
+	_, total := catalog.ListEntities(esui.ListOptions{})
+	assert.Equal(t, 0, total)
+	items, _ := catalog.ListEntities(esui.ListOptions{IncludeArchived: true})
+	require.Len(t, items, 1)
+	assert.True(t, items[0].Archived)
+
+	idgenerator.On("Generate").Return("user2").Once()
+	otherID, err := es.CreateEntity(ctx, "user")
+	require.NoError(t, err)
+	assert.ErrorIs(t, es.RestoreEntity(ctx, entityID), esui.ErrEntityExists)
+	require.NoError(t, es.RenameEntity(ctx, otherID, "member"))
+
+	require.NoError(t, es.RestoreEntity(ctx, entityID))
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_deleted"))
+	require.NoError(t, subscription.CatchUp(ctx))
+	_, total = catalog.ListEntities(esui.ListOptions{})
+	assert.Equal(t, 2, total)
+}
+
+func TestArchiveProjection(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "projection1")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "table1"))
+
+	require.NoError(t, es.ArchiveProjection(ctx, projectionID))
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	assert.True(t, projection.Archived)
+
+	assert.ErrorIs(t, es.CreateTable(ctx, projectionID, "table2"), esui.ErrArchived)
+	assert.ErrorIs(t, es.AddColumn(ctx, projectionID, "table1", "column1", "string"), esui.ErrArchived)
+	assert.ErrorIs(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "block1"}), esui.ErrArchived)
+
+	require.NoError(t, es.RestoreProjection(ctx, projectionID))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "table1", "column1", "string"))
+	assert.ErrorIs(t, es.RestoreProjection(ctx, "unknown"), esui.ErrProjectionNotFound)
+}
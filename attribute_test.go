@@ -0,0 +1,95 @@
+package esui_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeSchemaValidate(t *testing.T) {
+	valid := []esui.AttributeSchema{
+		{Type: esui.TypeFloat},
+		{Type: esui.TypeDatetime, Nullable: true},
+		{Type: esui.TypeDecimal, Default: json.RawMessage(`"10.50"`)},
+		{Type: esui.TypeEnum, Values: []string{"draft", "paid"}, Default: json.RawMessage(`"draft"`)},
+		{Type: esui.TypeArray, Items: &esui.AttributeSchema{Type: esui.TypeInt}},
+		{Type: esui.TypeObject, Fields: map[esui.AttributeName]esui.AttributeSchema{
+			"sku": {Type: esui.TypeString, Required: true},
+		}},
+	}
+	for _, schema := range valid {
+		assert.NoError(t, schema.Validate(), schema.Type)
+	}
+
+	invalid := []esui.AttributeSchema{
+		{Type: "money"},
+		{Type: esui.TypeEnum},
+		{Type: esui.TypeEnum, Values: []string{"draft", "draft"}},
+		{Type: esui.TypeArray},
+		{Type: esui.TypeArray, Items: &esui.AttributeSchema{Type: "money"}},
+		{Type: esui.TypeObject},
+		{Type: esui.TypeBool, Default: json.RawMessage(`"yes"`)},
+		{Type: esui.TypeEnum, Values: []string{"draft"}, Default: json.RawMessage(`"paid"`)},
+	}
+	for _, schema := range invalid {
+		assert.ErrorIs(t, schema.Validate(), esui.ErrInvalidType, schema.Type)
+	}
+}
+
+func TestAttributeSchemaCheckValue(t *testing.T) {
+	lineItem := esui.AttributeSchema{Type: esui.TypeObject, Fields: map[esui.AttributeName]esui.AttributeSchema{
+		"sku":      {Type: esui.TypeString, Required: true},
+		"quantity": {Type: esui.TypeInt},
+		"price":    {Type: esui.TypeDecimal},
+	}}
+	items := esui.AttributeSchema{Type: esui.TypeArray, Items: &lineItem}
+
+	assert.NoError(t, items.CheckValue(json.RawMessage(`[{"sku":"a","quantity":2,"price":"1.25"},{"sku":"b"}]`)))
+	assert.Error(t, items.CheckValue(json.RawMessage(`[{"quantity":2}]`)))
+	assert.Error(t, items.CheckValue(json.RawMessage(`[{"sku":"a","quantity":1.5}]`)))
+	assert.Error(t, items.CheckValue(json.RawMessage(`[{"sku":"a","color":"red"}]`)))
+	assert.Error(t, items.CheckValue(json.RawMessage(`null`)))
+
+	assert.NoError(t, esui.AttributeSchema{Type: esui.TypeDatetime}.CheckValue(json.RawMessage(`"2024-01-02T15:04:05Z"`)))
+	assert.Error(t, esui.AttributeSchema{Type: esui.TypeDatetime}.CheckValue(json.RawMessage(`"yesterday"`)))
+	assert.NoError(t, esui.AttributeSchema{Type: esui.TypeString, Nullable: true}.CheckValue(json.RawMessage(`null`)))
+}
+
+func TestAddAttributeSchema(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("order1").Once()
+	entityID, err := es.CreateEntity(ctx, "order")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "order_placed"))
+
+	status := esui.AttributeSchema{Type: esui.TypeEnum, Values: []string{"draft", "paid"}, Required: true, Default: json.RawMessage(`"draft"`)}
+	lines := esui.AttributeSchema{Type: esui.TypeArray, Items: &esui.AttributeSchema{
+		Type: esui.TypeObject,
+		Fields: map[esui.AttributeName]esui.AttributeSchema{
+			"sku":   {Type: esui.TypeString, Required: true},
+			"price": {Type: esui.TypeDecimal},
+		},
+	}}
+	require.NoError(t, es.AddAttributeSchema(ctx, entityID, "order_placed", "status", status))
+	require.NoError(t, es.AddAttributeSchema(ctx, entityID, "order_placed", "lines", lines))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "order_placed", "paid", esui.TypeBool))
+
+	err = es.AddAttributeSchema(ctx, entityID, "order_placed", "kind", esui.AttributeSchema{Type: esui.TypeEnum})
+	assert.ErrorIs(t, err, esui.ErrInvalidType)
+
+	entity, err := es.GetEntity(ctx, entityID)
+	require.NoError(t, err)
+	event := entity.Events["order_placed"]
+	assert.Equal(t, esui.TypeEnum, event.Attributes["status"])
+	assert.Equal(t, status, event.Schemas["status"])
+	assert.Equal(t, lines, event.Schemas["lines"])
+	assert.Equal(t, esui.AttributeSchema{Type: esui.TypeBool}, event.Schemas["paid"])
+}
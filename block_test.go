@@ -0,0 +1,148 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockIDs(blocks []esui.Block) (ids []string) {
+	for _, block := range blocks {
+		ids = append(ids, block.BlockID)
+	}
+	return
+}
+
+func TestGetProjectionWithBlocks(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "projection1")
+	require.NoError(t, err)
+
+	script := "table.insert(event)"
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "a", Type: "javascript", Data: esui.BlockData{Javascript: &script}}))
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "c", OrderedAfter: "a"}))
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "b", OrderedAfter: "a"}))
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "d", OrderedAfter: "c"}))
+
+	assert.ErrorIs(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "a"}), esui.ErrBlockExists)
+	assert.ErrorIs(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "e", OrderedAfter: "x"}), esui.ErrBlockNotFound)
+	assert.ErrorIs(t, es.AddBlock(ctx, projectionID, esui.Block{}), esui.ErrEmptyBlockID)
+
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	require.Len(t, projection.Blocks, 4)
+	assert.Equal(t, script, *projection.Blocks[0].Data.Javascript)
+
+	blocks, err := projection.OrderedBlocks()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, blockIDs(blocks))
+}
+
+func TestOrderedBlocksErrors(t *testing.T) {
+	projection := esui.EsuiProjection{Blocks: []esui.Block{
+		{BlockID: "a"},
+		{BlockID: "b", OrderedAfter: "a"},
+		{BlockID: "a", OrderedAfter: "b"},
+		{BlockID: "c", OrderedAfter: "missing"},
+		{BlockID: "d", OrderedAfter: "c"},
+		{BlockID: "e", OrderedAfter: "f"},
+		{BlockID: "f", OrderedAfter: "e"},
+	}}
+
+	blocks, err := projection.OrderedBlocks()
+	assert.Equal(t, []string{"a", "b"}, blockIDs(blocks))
+	assert.ErrorIs(t, err, esui.ErrBlockDuplicate)
+	assert.ErrorIs(t, err, esui.ErrBlockDangling)
+	assert.ErrorIs(t, err, esui.ErrBlockCycle)
+	assert.Contains(t, err.Error(), "c after missing")
+	assert.NotContains(t, err.Error(), ": d ")
+}
+
+func TestBlockEditing(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "projection1")
+	require.NoError(t, err)
+	for _, block := range []esui.Block{
+		{BlockID: "a"},
+		{BlockID: "b", OrderedAfter: "a"},
+		{BlockID: "c", OrderedAfter: "b"},
+		{BlockID: "d", OrderedAfter: "c"},
+	} {
+		require.NoError(t, es.AddBlock(ctx, projectionID, block))
+	}
+
+	ordered := func() []string {
+		projection, err := es.GetProjection(ctx, projectionID)
+		require.NoError(t, err)
+		blocks, err := projection.OrderedBlocks()
+		require.NoError(t, err)
+		return blockIDs(blocks)
+	}
+
+	t.Run("Move", func(t *testing.T) {
+		require.NoError(t, es.MoveBlock(ctx, projectionID, "b", "c"))
+		assert.Equal(t, []string{"a", "c", "b", "d"}, ordered())
+		require.NoError(t, es.MoveBlock(ctx, projectionID, "d", ""))
+		assert.Equal(t, []string{"d", "a", "c", "b"}, ordered())
+
+		assert.ErrorIs(t, es.MoveBlock(ctx, projectionID, "a", "a"), esui.ErrBlockCycle)
+		assert.ErrorIs(t, es.MoveBlock(ctx, projectionID, "a", "x"), esui.ErrBlockNotFound)
+		assert.ErrorIs(t, es.MoveBlock(ctx, projectionID, "x", "a"), esui.ErrBlockNotFound)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		script := "table.insert(event)"
+		require.NoError(t, es.UpdateBlock(ctx, projectionID, esui.Block{
+			BlockID: "c",
+			Name:    "insert",
+			Type:    "javascript",
+			Data:    esui.BlockData{Javascript: &script},
+		}))
+		projection, err := es.GetProjection(ctx, projectionID)
+		require.NoError(t, err)
+		blocks, err := projection.OrderedBlocks()
+		require.NoError(t, err)
+		assert.Equal(t, "insert", blocks[2].Name)
+		assert.Equal(t, "a", blocks[2].OrderedAfter)
+		assert.ErrorIs(t, es.UpdateBlock(ctx, projectionID, esui.Block{BlockID: "x"}), esui.ErrBlockNotFound)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		idgenerator.On("Generate").Return("c2").Once()
+		newBlockID, err := es.DuplicateBlock(ctx, projectionID, "c")
+		require.NoError(t, err)
+		assert.Equal(t, "c2", newBlockID)
+		assert.Equal(t, []string{"d", "a", "c", "c2", "b"}, ordered())
+
+		projection, err := es.GetProjection(ctx, projectionID)
+		require.NoError(t, err)
+		blocks, _ := projection.OrderedBlocks()
+		assert.Equal(t, "insert", blocks[3].Name)
+		assert.Equal(t, *blocks[2].Data.Javascript, *blocks[3].Data.Javascript)
+
+		idgenerator.On("Generate").Return("a").Once()
+		_, err = es.DuplicateBlock(ctx, projectionID, "c")
+		assert.ErrorIs(t, err, esui.ErrBlockExists)
+		assert.Equal(t, []string{"d", "a", "c", "c2", "b"}, ordered())
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		require.NoError(t, es.RemoveBlock(ctx, projectionID, "c"))
+		assert.Equal(t, []string{"d", "a", "c2", "b"}, ordered())
+		require.NoError(t, es.RemoveBlock(ctx, projectionID, "d"))
+		assert.Equal(t, []string{"a", "c2", "b"}, ordered())
+		assert.ErrorIs(t, es.RemoveBlock(ctx, projectionID, "d"), esui.ErrBlockNotFound)
+	})
+}
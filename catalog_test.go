@@ -0,0 +1,85 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	for _, entity := range []struct{ id, name string }{
+		{"ent1", "user"},
+		{"ent2", "product"},
+		{"ent3", "order"},
+		{"ent4", "product_review"},
+	} {
+		idgenerator.On("Generate").Return(entity.id).Once()
+		_, err := es.CreateEntity(ctx, entity.name)
+		require.NoError(t, err)
+	}
+	idgenerator.On("Generate").Return("proj1").Once()
+	_, err := es.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, "ent1", "user_created"))
+
+	catalog := esui.NewCatalog()
+	subscription := esui.NewSubscription("catalog", estore, eventstore.NewMemoryCheckpoints(), catalog.Handle)
+	require.NoError(t, subscription.CatchUp(ctx))
+
+	t.Run("List In Creation Order", func(t *testing.T) {
+		items, total := catalog.ListEntities(esui.ListOptions{})
+		assert.Equal(t, 4, total)
+		require.Len(t, items, 4)
+		assert.Equal(t, "user", items[0].Name)
+		assert.EqualValues(t, "ent4", items[3].ID)
+	})
+
+	t.Run("Filter Sort And Page", func(t *testing.T) {
+		items, total := catalog.ListEntities(esui.ListOptions{
+			NameContains: "PRODUCT",
+			SortBy:       esui.SortByName,
+			Descending:   true,
+			Limit:        1,
+		})
+		assert.Equal(t, 2, total)
+		require.Len(t, items, 1)
+		assert.Equal(t, "product_review", items[0].Name)
+
+		items, _ = catalog.ListEntities(esui.ListOptions{SortBy: esui.SortByName, Offset: 1, Limit: 2})
+		require.Len(t, items, 2)
+		assert.Equal(t, "product", items[0].Name)
+		assert.Equal(t, "product_review", items[1].Name)
+
+		items, total = catalog.ListEntities(esui.ListOptions{Offset: 10})
+		assert.Equal(t, 4, total)
+		assert.Empty(t, items)
+	})
+
+	t.Run("Projections", func(t *testing.T) {
+		items, total := catalog.ListProjections(esui.ListOptions{})
+		assert.Equal(t, 1, total)
+		assert.Equal(t, "product_list", items[0].Name)
+	})
+
+	t.Run("Find By Name", func(t *testing.T) {
+		item, err := catalog.FindEntityByName("order")
+		require.NoError(t, err)
+		assert.EqualValues(t, "ent3", item.ID)
+
+		_, err = catalog.FindEntityByName("missing")
+		assert.ErrorIs(t, err, esui.ErrEntityNotFound)
+
+		item, err = catalog.FindProjectionByName("product_list")
+		require.NoError(t, err)
+		assert.EqualValues(t, "proj1", item.ID)
+	})
+}
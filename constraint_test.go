@@ -0,0 +1,140 @@
+package esui_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestValidatePayload(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("user1").Once()
+	entityID, err := es.CreateEntity(ctx, "user")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_registered"))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "user_registered", "username", esui.TypeString))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "user_registered", "age", esui.TypeInt))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "user_registered", "balance", esui.TypeDecimal))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "user_registered", "nickname", esui.TypeString))
+
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "user_registered", "username", esui.AttributeConstraint{
+		MinLength: intPtr(3),
+		MaxLength: intPtr(12),
+		Pattern:   "^[a-z0-9_]+$",
+		Required:  true,
+	}))
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "user_registered", "age", esui.AttributeConstraint{
+		Min: floatPtr(13),
+		Max: floatPtr(120),
+	}))
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "user_registered", "balance", esui.AttributeConstraint{
+		Min: floatPtr(0),
+	}))
+
+	t.Run("Invalid Constraints", func(t *testing.T) {
+		err := es.SetAttributeConstraint(ctx, entityID, "user_registered", "age", esui.AttributeConstraint{MinLength: intPtr(1)})
+		assert.ErrorIs(t, err, esui.ErrInvalidConstraint)
+		err = es.SetAttributeConstraint(ctx, entityID, "user_registered", "username", esui.AttributeConstraint{Pattern: "("})
+		assert.ErrorIs(t, err, esui.ErrInvalidConstraint)
+		err = es.SetAttributeConstraint(ctx, entityID, "user_registered", "age", esui.AttributeConstraint{Min: floatPtr(5), Max: floatPtr(1)})
+		assert.ErrorIs(t, err, esui.ErrInvalidConstraint)
+		err = es.SetAttributeConstraint(ctx, entityID, "user_registered", "email", esui.AttributeConstraint{Required: true})
+		assert.ErrorIs(t, err, esui.ErrAttributeNotFound)
+	})
+
+	t.Run("Constraints On Entity", func(t *testing.T) {
+		entity, err := es.GetEntity(ctx, entityID)
+		require.NoError(t, err)
+		assert.Equal(t, "^[a-z0-9_]+$", entity.Events["user_registered"].Constraints["username"].Pattern)
+	})
+
+	t.Run("Valid Payload", func(t *testing.T) {
+		violations, err := es.ValidatePayload(ctx, entityID, "user_registered", json.RawMessage(`{"username":"arief","age":30,"balance":"10.5"}`))
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+	})
+
+	t.Run("Every Violation Reported", func(t *testing.T) {
+		violations, err := es.ValidatePayload(ctx, entityID, "user_registered", json.RawMessage(`{"age":7,"balance":"-1","nickname":5,"email":"a@b"}`))
+		require.NoError(t, err)
+		assert.Equal(t, []esui.Violation{
+			{Attribute: "age", Message: "7 is below minimum 13"},
+			{Attribute: "balance", Message: "-1 is below minimum 0"},
+			{Attribute: "email", Message: "unknown attribute"},
+			{Attribute: "nickname", Message: "expected string, got 5"},
+			{Attribute: "username", Message: "required"},
+		}, violations)
+
+		violations, err = es.ValidatePayload(ctx, entityID, "user_registered", json.RawMessage(`{"username":"A!"}`))
+		require.NoError(t, err)
+		assert.Len(t, violations, 2)
+	})
+
+	t.Run("Invalid Stored Pattern", func(t *testing.T) {
+		estore := eventstore.NewMemory()
+		es := esui.NewEsui(estore, idgenerator)
+		idgenerator.On("Generate").Return("user2").Once()
+		entityID, err := es.CreateEntity(ctx, "user")
+		require.NoError(t, err)
+		require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_registered"))
+		require.NoError(t, es.AddAttribute(ctx, entityID, "user_registered", "username", esui.TypeString))
+		require.NoError(t, estore.StoreEvent(ctx, string(entityID), "entity", "attribute_constraint_set", esui.EsuiAttributeConstraintSet{
+			EventName:  "user_registered",
+			Name:       "username",
+			Constraint: esui.AttributeConstraint{Pattern: "("},
+		}, esui.AnyVersion))
+
+		violations, err := es.ValidatePayload(ctx, entityID, "user_registered", json.RawMessage(`{"username":"arief"}`))
+		require.NoError(t, err)
+		assert.Equal(t, []esui.Violation{{Attribute: "username", Message: "invalid pattern ("}}, violations)
+	})
+
+	t.Run("Default Satisfies Required", func(t *testing.T) {
+		es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+		idgenerator.On("Generate").Return("user3").Once()
+		entityID, err := es.CreateEntity(ctx, "user")
+		require.NoError(t, err)
+		require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_registered"))
+		require.NoError(t, es.AddAttributeSchema(ctx, entityID, "user_registered", "role", esui.AttributeSchema{
+			Type:     esui.TypeString,
+			Required: true,
+			Default:  json.RawMessage(`"member"`),
+		}))
+		require.NoError(t, es.AddAttributeSchema(ctx, entityID, "user_registered", "address", esui.AttributeSchema{
+			Type: esui.TypeObject,
+			Fields: map[esui.AttributeName]esui.AttributeSchema{
+				"country": {Type: esui.TypeString, Required: true, Default: json.RawMessage(`"ID"`)},
+				"city":    {Type: esui.TypeString, Required: true},
+			},
+		}))
+
+		violations, err := es.ValidatePayload(ctx, entityID, "user_registered", json.RawMessage(`{"address":{"city":"Bandung"}}`))
+		require.NoError(t, err)
+		assert.Empty(t, violations)
+
+		violations, err = es.ValidatePayload(ctx, entityID, "user_registered", json.RawMessage(`{"address":{}}`))
+		require.NoError(t, err)
+		assert.Equal(t, []esui.Violation{{Attribute: "address", Message: "city: required"}}, violations)
+	})
+
+	t.Run("Unknown Event", func(t *testing.T) {
+		_, err := es.ValidatePayload(ctx, entityID, "user_deleted", json.RawMessage(`{}`))
+		assert.ErrorIs(t, err, esui.ErrEventNotFound)
+	})
+}
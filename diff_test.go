@@ -0,0 +1,210 @@
+package esui_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffApplicationVersions(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "name", esui.TypeString))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "price", esui.TypeInt))
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "products"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "products", "name", "string"))
+	idgenerator.On("Generate").Return("app1").Once()
+	applicationID, err := es.CreateApplication(ctx, "shop")
+	require.NoError(t, err)
+	require.NoError(t, es.AttachEntity(ctx, applicationID, entityID))
+	require.NoError(t, es.AttachProjection(ctx, applicationID, projectionID))
+	first, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+
+	t.Run("Compatible", func(t *testing.T) {
+		require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "color", esui.TypeString))
+		require.NoError(t, es.AddColumn(ctx, projectionID, "products", "price", "int"))
+		second, err := es.PublishApplicationVersion(ctx, applicationID)
+		require.NoError(t, err)
+
+		diff, err := es.DiffApplicationVersions(ctx, first, second)
+		require.NoError(t, err)
+		assert.False(t, diff.Breaking())
+		assert.Equal(t, []esui.SchemaChange{
+			{Kind: esui.ChangeAdded, Object: "attribute", Path: "product.product_created.color"},
+			{Kind: esui.ChangeAdded, Object: "column", Path: "product_list.products.price"},
+		}, diff.Changes)
+	})
+
+	t.Run("Breaking", func(t *testing.T) {
+		require.NoError(t, es.RenameEntity(ctx, entityID, "item"))
+		require.NoError(t, es.ChangeAttributeType(ctx, entityID, "product_created", "price", esui.TypeDecimal))
+		require.NoError(t, es.RemoveAttribute(ctx, entityID, "product_created", "name"))
+		require.NoError(t, es.DropColumn(ctx, projectionID, "products", "name"))
+		script := "return;"
+		require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "b1", Type: "javascript", Data: esui.BlockData{Javascript: &script}}))
+		third, err := es.PublishApplicationVersion(ctx, applicationID)
+		require.NoError(t, err)
+
+		diff, err := es.DiffApplicationVersions(ctx, first, third)
+		require.NoError(t, err)
+		assert.True(t, diff.Breaking())
+		assert.Equal(t, []esui.SchemaChange{
+			{Kind: esui.ChangeRenamed, Object: "entity", Path: "item", From: "product", To: "item", Breaking: true},
+			{Kind: esui.ChangeTypeChanged, Object: "attribute", Path: "item.product_created.price", From: "int", To: "decimal", Breaking: true},
+			{Kind: esui.ChangeAdded, Object: "attribute", Path: "item.product_created.color"},
+			{Kind: esui.ChangeRemoved, Object: "attribute", Path: "item.product_created.name", Breaking: true},
+			{Kind: esui.ChangeAdded, Object: "column", Path: "product_list.products.price"},
+			{Kind: esui.ChangeRemoved, Object: "column", Path: "product_list.products.name", Breaking: true},
+			{Kind: esui.ChangeAdded, Object: "block", Path: "product_list.b1"},
+		}, diff.Changes)
+	})
+}
+
+func TestDiffEntity(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "nme", esui.TypeString))
+	entity, err := es.GetEntity(ctx, entityID)
+	require.NoError(t, err)
+	before := entity.Version
+
+	require.NoError(t, es.RenameEvent(ctx, entityID, "product_created", "product_added"))
+	require.NoError(t, es.RenameAttribute(ctx, entityID, "product_added", "nme", "name"))
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+
+	diff, err := es.DiffEntity(ctx, entityID, before, esui.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, []esui.SchemaChange{
+		{Kind: esui.ChangeRenamed, Object: "event", Path: "product.product_added", From: "product_created", To: "product_added", Breaking: true},
+		{Kind: esui.ChangeRenamed, Object: "attribute", Path: "product.product_added.name", From: "nme", To: "name", Breaking: true},
+		{Kind: esui.ChangeAdded, Object: "event", Path: "product.product_created"},
+	}, diff.Changes)
+
+	diff, err = es.DiffEntity(ctx, entityID, 0, before)
+	require.NoError(t, err)
+	assert.Equal(t, []esui.SchemaChange{{Kind: esui.ChangeAdded, Object: "entity", Path: "product"}}, diff.Changes)
+
+	_, err = es.DiffEntity(ctx, "unknown", 0, esui.AnyVersion)
+	assert.ErrorIs(t, err, esui.ErrEntityNotFound)
+}
+
+func TestDiffSchemaClassification(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("order1").Once()
+	entityID, err := es.CreateEntity(ctx, "order")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "order_placed"))
+	require.NoError(t, es.AddAttributeSchema(ctx, entityID, "order_placed", "status", esui.AttributeSchema{
+		Type:   esui.TypeEnum,
+		Values: []string{"new", "paid", "shipped"},
+	}))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "order_placed", "note", esui.TypeString))
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "order_placed", "note", esui.AttributeConstraint{MaxLength: intPtr(10)}))
+	idgenerator.On("Generate").Return("app1").Once()
+	applicationID, err := es.CreateApplication(ctx, "shop")
+	require.NoError(t, err)
+	require.NoError(t, es.AttachEntity(ctx, applicationID, entityID))
+	first, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+
+	require.NoError(t, es.ChangeAttributeSchema(ctx, entityID, "order_placed", "status", esui.AttributeSchema{
+		Type:   esui.TypeEnum,
+		Values: []string{"new", "paid"},
+	}))
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "order_placed", "note", esui.AttributeConstraint{MaxLength: intPtr(20)}))
+	require.NoError(t, es.AddAttributeSchema(ctx, entityID, "order_placed", "currency", esui.AttributeSchema{Type: esui.TypeString, Required: true}))
+	require.NoError(t, es.AddAttributeSchema(ctx, entityID, "order_placed", "channel", esui.AttributeSchema{
+		Type:     esui.TypeString,
+		Required: true,
+		Default:  json.RawMessage(`"web"`),
+	}))
+	second, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+
+	diff, err := es.DiffApplicationVersions(ctx, first, second)
+	require.NoError(t, err)
+	assert.Equal(t, []esui.SchemaChange{
+		{Kind: esui.ChangeModified, Object: "attribute", Path: "order.order_placed.note", Detail: "max_length loosened"},
+		{Kind: esui.ChangeModified, Object: "attribute", Path: "order.order_placed.status", Detail: "values narrowed", Breaking: true},
+		{Kind: esui.ChangeAdded, Object: "attribute", Path: "order.order_placed.channel"},
+		{Kind: esui.ChangeAdded, Object: "attribute", Path: "order.order_placed.currency", Breaking: true},
+	}, diff.Changes)
+
+	require.NoError(t, es.ChangeAttributeSchema(ctx, entityID, "order_placed", "status", esui.AttributeSchema{
+		Type:   esui.TypeEnum,
+		Values: []string{"new", "paid", "refunded"},
+	}))
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "order_placed", "note", esui.AttributeConstraint{MaxLength: intPtr(20), MinLength: intPtr(2)}))
+	third, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+
+	diff, err = es.DiffApplicationVersions(ctx, second, third)
+	require.NoError(t, err)
+	assert.Equal(t, []esui.SchemaChange{
+		{Kind: esui.ChangeModified, Object: "attribute", Path: "order.order_placed.note", Detail: "min_length tightened", Breaking: true},
+		{Kind: esui.ChangeModified, Object: "attribute", Path: "order.order_placed.status", Detail: "values widened"},
+	}, diff.Changes)
+}
+
+func TestDiffProjection(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "order_list")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "ordrs"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "ordrs", "id", "string"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "ordrs", "totl", "int"))
+	require.NoError(t, es.SetPrimaryKey(ctx, projectionID, "ordrs", "id"))
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "b1", Name: "script", Type: "javascript"}))
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	before := projection.Version
+
+	require.NoError(t, es.RenameTable(ctx, projectionID, "ordrs", "orders"))
+	require.NoError(t, es.RenameColumn(ctx, projectionID, "orders", "totl", "total"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "orders", "note", "string"))
+	require.NoError(t, es.UpdateBlock(ctx, projectionID, esui.Block{BlockID: "b1", Name: "insert orders", Type: "javascript"}))
+
+	diff, err := es.DiffProjection(ctx, projectionID, before, esui.AnyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, []esui.SchemaChange{
+		{Kind: esui.ChangeRenamed, Object: "table", Path: "order_list.orders", From: "ordrs", To: "orders", Breaking: true},
+		{Kind: esui.ChangeRenamed, Object: "column", Path: "order_list.orders.total", From: "totl", To: "total", Breaking: true},
+		{Kind: esui.ChangeAdded, Object: "column", Path: "order_list.orders.note"},
+		{Kind: esui.ChangeRenamed, Object: "block", Path: "order_list.b1", From: "script", To: "insert orders"},
+	}, diff.Changes)
+
+	diff, err = es.DiffProjection(ctx, projectionID, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []esui.SchemaChange{{Kind: esui.ChangeAdded, Object: "projection", Path: "order_list"}}, diff.Changes)
+
+	_, err = es.DiffProjection(ctx, "unknown", 0, esui.AnyVersion)
+	assert.ErrorIs(t, err, esui.ErrProjectionNotFound)
+}
@@ -0,0 +1,82 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntityChanges(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "prodct")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_updated"))
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_removed"))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "nme", esui.TypeString))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "price", esui.TypeInt))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "color", esui.TypeString))
+	require.NoError(t, es.SetAttributeConstraint(ctx, entityID, "product_created", "nme", esui.AttributeConstraint{MaxLength: intPtr(20)}))
+
+	t.Run("Apply Changes", func(t *testing.T) {
+		require.NoError(t, es.RenameEntity(ctx, entityID, "product"))
+		require.NoError(t, es.RemoveEventFromEntity(ctx, entityID, "product_removed"))
+		require.NoError(t, es.RenameEvent(ctx, entityID, "product_updated", "product_changed"))
+		require.NoError(t, es.RenameAttribute(ctx, entityID, "product_created", "nme", "name"))
+		require.NoError(t, es.ChangeAttributeType(ctx, entityID, "product_created", "price", esui.TypeDecimal))
+		require.NoError(t, es.RemoveAttribute(ctx, entityID, "product_created", "color"))
+
+		entity, err := es.GetEntity(ctx, entityID)
+		require.NoError(t, err)
+		assert.Equal(t, "product", entity.Name)
+		assert.NotContains(t, entity.Events, "product_removed")
+		assert.NotContains(t, entity.Events, "product_updated")
+		assert.Contains(t, entity.Events, "product_changed")
+
+		created := entity.Events["product_created"]
+		assert.Equal(t, map[esui.AttributeName]esui.AttributeType{
+			"name":  esui.TypeString,
+			"price": esui.TypeDecimal,
+		}, created.Attributes)
+		assert.Equal(t, esui.AttributeSchema{Type: esui.TypeDecimal}, created.Schemas["price"])
+		assert.Equal(t, 20, *created.Constraints["name"].MaxLength)
+		assert.NotContains(t, created.Schemas, "color")
+	})
+
+	t.Run("Guards", func(t *testing.T) {
+		assert.ErrorIs(t, es.RenameEntity(ctx, "unknown", "x"), esui.ErrEntityNotFound)
+		assert.ErrorIs(t, es.RenameEntity(ctx, entityID, ""), esui.ErrEmptyName)
+		idgenerator.On("Generate").Return("ord1").Once()
+		orderID, err := es.CreateEntity(ctx, "order")
+		require.NoError(t, err)
+		assert.ErrorIs(t, es.RenameEntity(ctx, orderID, "product"), esui.ErrEntityExists)
+		idgenerator.On("Generate").Return("prod2").Once()
+		_, err = es.CreateEntity(ctx, "product")
+		assert.ErrorIs(t, err, esui.ErrEntityExists)
+		assert.ErrorIs(t, es.RemoveEventFromEntity(ctx, entityID, "product_removed"), esui.ErrEventNotFound)
+		assert.ErrorIs(t, es.RenameEvent(ctx, entityID, "product_changed", "product_created"), esui.ErrEventExists)
+		assert.ErrorIs(t, es.RenameAttribute(ctx, entityID, "product_created", "color", "colour"), esui.ErrAttributeNotFound)
+		assert.ErrorIs(t, es.RenameAttribute(ctx, entityID, "product_created", "price", "name"), esui.ErrAttributeExists)
+		assert.ErrorIs(t, es.ChangeAttributeType(ctx, entityID, "product_created", "price", "money"), esui.ErrInvalidType)
+		assert.ErrorIs(t, es.ChangeAttributeType(ctx, entityID, "product_created", "name", esui.TypeInt), esui.ErrInvalidConstraint)
+	})
+
+	t.Run("Catalog Follows Rename", func(t *testing.T) {
+		catalog := esui.NewCatalog()
+		require.NoError(t, esui.NewSubscription("catalog", estore, eventstore.NewMemoryCheckpoints(), catalog.Handle).CatchUp(ctx))
+		item, err := catalog.FindEntityByName("product")
+		require.NoError(t, err)
+		assert.EqualValues(t, entityID, item.ID)
+		_, err = catalog.FindEntityByName("prodct")
+		assert.ErrorIs(t, err, esui.ErrEntityNotFound)
+	})
+}
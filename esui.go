@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/ariefsam/esui/logger"
 )
@@ -29,35 +30,47 @@ func init() {
 
 }
 
+var (
+	ErrEntityNotFound     = errors.New("entity not found")
+	ErrEntityExists       = errors.New("entity already exist")
+	ErrProjectionNotFound = errors.New("projection not found")
+	ErrTableNotFound      = errors.New("table not found")
+	ErrEventNotFound      = errors.New("event not found")
+	ErrEventExists        = errors.New("event already exist")
+	ErrAttributeExists    = errors.New("attribute already exist")
+	ErrAttributeNotFound  = errors.New("attribute not found")
+	ErrInvalidType        = errors.New("invalid attribute type")
+	ErrArchived           = errors.New("archived")
+	ErrNotArchived        = errors.New("not archived")
+)
+
 type Esui struct {
 	eventstore eventstoreDB
 	idgenerator
+	retryPolicy RetryPolicy
+	index       schemaIndex
 }
 
 type idgenerator interface {
 	Generate() string
 }
 
-type AttributeName string
-type AttributeType string
-
-func (atype AttributeType) Validate() error {
-	if atype != "string" && atype != "int" {
-		return errors.New("Invalid attribute type")
-	}
-	return nil
-}
-
 type ShortID string
 
 type EsuiEntity struct {
-	ID     ShortID                    `json:"entity_id"`
-	Name   string                     `json:"name"`
-	Events map[string]EsuiEntityEvent `json:"events"`
+	ID       ShortID                    `json:"entity_id"`
+	Name     string                     `json:"name"`
+	Events   map[string]EsuiEntityEvent `json:"events"`
+	Archived bool                       `json:"archived"`
+	Version  int64                      `json:"version"`
 }
 
+// EsuiEntityEvent keeps the type of every attribute in Attributes and its
+// full schema in Schemas, both filled during replay.
 type EsuiEntityEvent struct {
-	Attributes map[AttributeName]AttributeType `json:"attribute"`
+	Attributes  map[AttributeName]AttributeType       `json:"attribute"`
+	Schemas     map[AttributeName]AttributeSchema     `json:"schemas,omitempty"`
+	Constraints map[AttributeName]AttributeConstraint `json:"constraints,omitempty"`
 }
 
 type EsuiEntityCreated struct {
@@ -68,10 +81,12 @@ type EsuiEventAdded struct {
 	Name string `json:"name"`
 }
 
+// EsuiAttributeAdded embeds the schema so its fields stay flat in the stored
+// payload, next to the event and attribute names.
 type EsuiAttributeAdded struct {
 	EventName string        `json:"event_name"`
 	Name      AttributeName `json:"name"`
-	Type      AttributeType `json:"type"`
+	AttributeSchema
 }
 
 type EsuiProjection struct {
@@ -79,48 +94,74 @@ type EsuiProjection struct {
 	Name     string               `json:"name"`
 	IsActive bool                 `json:"is_active"`
 	Tables   map[string]EsuiTable `json:"tables"`
+	Blocks   []Block              `json:"blocks"`
+	// Subscriptions holds, per entity ID, the entity events the projection
+	// consumes.
+	Subscriptions map[ShortID]map[string]bool `json:"subscriptions"`
+	Archived      bool                        `json:"archived"`
+	Version       int64                       `json:"version"`
 }
 
 type EsuiTable struct {
 	Name         string                `json:"name"`
 	ProjectionID ShortID               `json:"projection_id"`
 	Columns      map[string]EsuiColumn `json:"columns"`
+	PrimaryKey   []string              `json:"primary_key"`
+	Indexes      map[string]EsuiIndex  `json:"indexes"`
 }
 
 type EsuiColumn struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
 }
 type EstoreEvent struct {
 	EventID       ShortID `json:"event_id"`
+	Position      int64   `json:"position"`
 	AggregateID   ShortID `json:"aggregate_id"`
 	AggregateName string  `json:"aggregate_name"`
 	EventName     string  `json:"event_name"`
 	Data          string  `json:"data"`
+	// Timestamp is when the event was stored, zero for events stored before
+	// stores recorded it.
+	Timestamp time.Time     `json:"timestamp"`
+	Metadata  EventMetadata `json:"metadata"`
 }
 
+// eventstoreDB is the store of the schema aggregates. It is an EventStream
+// too, finding the projections that consume an event reads every projection.
 type eventstoreDB interface {
-	StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}) (err error)
+	EventStream
+	StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}, expectedVersion int64) (err error)
 	FetchAggregateEvents(ctx context.Context, aggregateID string, aggregateName string, fromID string) (events []EstoreEvent, err error)
 }
 
 func NewEsui(
 	eventstore eventstoreDB,
 	idgenerator idgenerator,
+	options ...Option,
 ) (obj *Esui) {
 	obj = &Esui{
 		eventstore:  eventstore,
 		idgenerator: idgenerator,
 	}
+	for _, option := range options {
+		option(obj)
+	}
 	return obj
 }
 
+// CreateEntity refuses a name that another entity that is not archived has
+// with ErrEntityExists.
 func (es *Esui) CreateEntity(ctx context.Context, entityName string) (entityID ShortID, err error) {
 	entityObj := EsuiEntityCreated{
 		Name: entityName,
 	}
 	entityID = ShortID(es.idgenerator.Generate())
-	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "created", entityObj)
+	err = es.checkEntityNameFree(ctx, entityID, entityName)
+	if err != nil {
+		return "", err
+	}
+	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "created", entityObj, 0)
 
 	if err != nil {
 		logger.Println(err)
@@ -137,19 +178,63 @@ func (es *Esui) GetEntity(ctx context.Context, entityID ShortID) (entity EsuiEnt
 	}
 
 	for _, event := range events {
-		switch event.EventName {
-		case "created":
-			entity.Created(event, entityID)
-		case "event_added":
-			entity.EventAdded(event)
-		case "attribute_added":
-			entity.AttributeAdded(event)
-		}
+		entity.apply(event, entityID)
 	}
+	entity.Version = int64(len(events))
 
 	return
 }
 
+// apply replays one event of the entity.
+func (entity *EsuiEntity) apply(event EstoreEvent, entityID ShortID) {
+	switch event.EventName {
+	case "created":
+		entity.Created(event, entityID)
+	case "event_added":
+		entity.EventAdded(event)
+	case "attribute_added":
+		entity.AttributeAdded(event)
+	case "attribute_constraint_set":
+		entity.AttributeConstraintSet(event)
+	case "renamed":
+		entity.Renamed(event)
+	case "event_removed":
+		entity.EventRemoved(event)
+	case "event_renamed":
+		entity.EventRenamed(event)
+	case "attribute_removed":
+		entity.AttributeRemoved(event)
+	case "attribute_renamed":
+		entity.AttributeRenamed(event)
+	case "attribute_type_changed":
+		entity.AttributeTypeChanged(event)
+	case "archived":
+		entity.Archived = true
+	case "restored":
+		entity.Archived = false
+	}
+}
+
+// loadEntity is GetEntity for commands. An entity that was never created is
+// reported as ErrEntityNotFound and an archived one as ErrArchived.
+func (es *Esui) loadEntity(ctx context.Context, entityID ShortID) (entity EsuiEntity, err error) {
+	entity, err = es.GetEntity(ctx, entityID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if entity.Name == "" {
+		err = ErrEntityNotFound
+		logger.Println(ctx, err)
+		return
+	}
+	if entity.Archived {
+		err = ErrArchived
+		logger.Println(ctx, err)
+	}
+	return
+}
+
 func (entity *EsuiEntity) Created(event EstoreEvent, entityID ShortID) {
 	var entityCreated EsuiEntityCreated
 	err := json.Unmarshal([]byte(event.Data), &entityCreated)
@@ -184,29 +269,32 @@ func (entity *EsuiEntity) AttributeAdded(event EstoreEvent) {
 	if entity.Events == nil {
 		entity.Events = make(map[string]EsuiEntityEvent)
 	}
-	if entity.Events[attributeAdded.EventName].Attributes == nil {
-		entity.Events[attributeAdded.EventName] = EsuiEntityEvent{
-			Attributes: make(map[AttributeName]AttributeType),
-		}
+	entityEvent := entity.Events[attributeAdded.EventName]
+	if entityEvent.Attributes == nil {
+		entityEvent.Attributes = make(map[AttributeName]AttributeType)
 	}
-	entity.Events[attributeAdded.EventName].Attributes[attributeAdded.Name] = attributeAdded.Type
+	if entityEvent.Schemas == nil {
+		entityEvent.Schemas = make(map[AttributeName]AttributeSchema)
+	}
+	entityEvent.Attributes[attributeAdded.Name] = attributeAdded.Type
+	entityEvent.Schemas[attributeAdded.Name] = attributeAdded.AttributeSchema
+	entity.Events[attributeAdded.EventName] = entityEvent
 }
 
 func (es *Esui) AddEventToEntity(ctx context.Context, entityID ShortID, eventName string) (err error) {
-	entity, err := es.GetEntity(ctx, entityID)
-	if err != nil {
-		logger.Println(err)
-		return
-	}
+	return es.retry(ctx, func() error {
+		return es.addEventToEntity(ctx, entityID, eventName)
+	})
+}
 
-	if entity.Name == "" {
-		err = errors.New("entity not found")
-		logger.Println(ctx, err)
+func (es *Esui) addEventToEntity(ctx context.Context, entityID ShortID, eventName string) (err error) {
+	entity, err := es.loadEntity(ctx, entityID)
+	if err != nil {
 		return
 	}
 
 	if _, ok := entity.Events[eventName]; ok {
-		err = errors.New("event already exist")
+		err = ErrEventExists
 		logger.Println(ctx, err)
 		return
 	}
@@ -214,17 +302,55 @@ func (es *Esui) AddEventToEntity(ctx context.Context, entityID ShortID, eventNam
 	dataEvent := EsuiEventAdded{
 		Name: eventName,
 	}
-	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "event_added", dataEvent)
+	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "event_added", dataEvent, entity.Version)
 
 	return
 }
 
 func (es *Esui) AddAttribute(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, attributeType AttributeType) (err error) {
-	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "attribute_added", EsuiAttributeAdded{
-		EventName: eventName,
-		Name:      attributeName,
-		Type:      attributeType,
+	return es.AddAttributeSchema(ctx, entityID, eventName, attributeName, AttributeSchema{
+		Type: attributeType,
+	})
+}
+
+// AddAttributeSchema adds an attribute described by a full schema, for types
+// that need more than a name such as enums, arrays and objects.
+func (es *Esui) AddAttributeSchema(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, schema AttributeSchema) (err error) {
+	return es.retry(ctx, func() error {
+		return es.addAttribute(ctx, entityID, eventName, attributeName, schema)
 	})
+}
+
+func (es *Esui) addAttribute(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, schema AttributeSchema) (err error) {
+	err = schema.Validate()
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	entity, err := es.loadEntity(ctx, entityID)
+	if err != nil {
+		return
+	}
+
+	event, ok := entity.Events[eventName]
+	if !ok {
+		err = ErrEventNotFound
+		logger.Println(ctx, err)
+		return
+	}
+
+	if _, ok := event.Schemas[attributeName]; ok {
+		err = ErrAttributeExists
+		logger.Println(ctx, err)
+		return
+	}
+
+	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "attribute_added", EsuiAttributeAdded{
+		EventName:       eventName,
+		Name:            attributeName,
+		AttributeSchema: schema,
+	}, entity.Version)
 
 	return
 }
@@ -238,7 +364,7 @@ func (es *Esui) CreateProjection(ctx context.Context, projectionName string) (pr
 		Name: projectionName,
 	}
 	projectionID = ShortID(es.idgenerator.Generate())
-	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "created", projectionObj)
+	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "created", projectionObj, 0)
 
 	if err != nil {
 		logger.Println(ctx, err)
@@ -256,19 +382,82 @@ func (es *Esui) GetProjection(ctx context.Context, projectionID ShortID) (projec
 
 	proj := EsuiProjection{}
 	for _, event := range events {
-		switch event.EventName {
-		case "created":
-			proj.HandleCreated(event, projectionID)
-		case "table_created":
-			proj.HandleTableCreated(event)
-		case "column_added":
-			proj.HandleColumnAdded(event)
-		}
+		proj.apply(event, projectionID)
 	}
+	proj.Version = int64(len(events))
 	projection = proj
 	return
 }
 
+// apply replays one event of the projection.
+func (projection *EsuiProjection) apply(event EstoreEvent, projectionID ShortID) {
+	switch event.EventName {
+	case "created":
+		projection.HandleCreated(event, projectionID)
+	case "table_created":
+		projection.HandleTableCreated(event)
+	case "column_added":
+		projection.HandleColumnAdded(event)
+	case "table_dropped":
+		projection.HandleTableDropped(event)
+	case "table_renamed":
+		projection.HandleTableRenamed(event)
+	case "column_dropped":
+		projection.HandleColumnDropped(event)
+	case "column_renamed":
+		projection.HandleColumnRenamed(event)
+	case "column_type_changed":
+		projection.HandleColumnTypeChanged(event)
+	case "primary_key_set":
+		projection.HandlePrimaryKeySet(event)
+	case "index_added":
+		projection.HandleIndexAdded(event)
+	case "index_removed":
+		projection.HandleIndexRemoved(event)
+	case "block_added":
+		projection.HandleBlockAdded(event)
+	case "block_updated":
+		projection.HandleBlockUpdated(event)
+	case "block_moved":
+		projection.HandleBlockMoved(event)
+	case "block_removed":
+		projection.HandleBlockRemoved(event)
+	case "block_duplicated":
+		projection.HandleBlockDuplicated(event)
+	case "subscribed":
+		projection.HandleSubscribed(event)
+	case "unsubscribed":
+		projection.HandleUnsubscribed(event)
+	case "activated":
+		projection.IsActive = true
+	case "deactivated":
+		projection.IsActive = false
+	case "archived":
+		projection.Archived = true
+	case "restored":
+		projection.Archived = false
+	}
+}
+
+// loadProjection is GetProjection for commands, see loadEntity.
+func (es *Esui) loadProjection(ctx context.Context, projectionID ShortID) (projection EsuiProjection, err error) {
+	projection, err = es.GetProjection(ctx, projectionID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if projection.Name == "" {
+		err = fmt.Errorf("%w: %s", ErrProjectionNotFound, projectionID)
+		logger.Println(ctx, err)
+		return
+	}
+	if projection.Archived {
+		err = ErrArchived
+		logger.Println(ctx, err)
+	}
+	return
+}
+
 func (projection *EsuiProjection) HandleCreated(event EstoreEvent, projectionID ShortID) {
 	var projectionCreated EsuiProjectionCreated
 	err := json.Unmarshal([]byte(event.Data), &projectionCreated)
@@ -329,41 +518,55 @@ type EsuiTableCreated struct {
 }
 
 func (es *Esui) CreateTable(ctx context.Context, projectionID ShortID, tableName string) (err error) {
+	return es.retry(ctx, func() error {
+		return es.createTable(ctx, projectionID, tableName)
+	})
+}
 
-	projection, err := es.GetProjection(ctx, projectionID)
+func (es *Esui) createTable(ctx context.Context, projectionID ShortID, tableName string) (err error) {
+	projection, err := es.loadInactiveProjection(ctx, projectionID)
 	if err != nil {
-		logger.Println(err)
 		return
 	}
 
-	if projection.Name == "" {
-		err = errors.New("projection not found: " + string(projectionID))
+	if _, ok := projection.Tables[tableName]; ok {
+		err = ErrTableExists
 		logger.Println(ctx, err)
 		return
 	}
 
 	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "table_created", EsuiTableCreated{
 		Name: tableName,
-	})
+	}, projection.Version)
 
 	return
 }
 
 type EsuiColumnAdded struct {
-	TableName  string `json:"table_name"`
-	ColumnName string `json:"column_name"`
-	ColumnType string `json:"column_type"`
+	TableName  string     `json:"table_name"`
+	ColumnName string     `json:"column_name"`
+	ColumnType ColumnType `json:"column_type"`
 }
 
+// AddColumn adds a column to a table. columnType must name one of the
+// ColumnType values, anything else is rejected with ErrInvalidColumnType, and
+// a column the table already has is rejected with ErrColumnExists.
 func (es *Esui) AddColumn(ctx context.Context, projectionID ShortID,
 	tableName string, columnName string, columnType string) (err error) {
-	projection, err := es.GetProjection(ctx, projectionID)
+	return es.retry(ctx, func() error {
+		return es.addColumn(ctx, projectionID, tableName, columnName, ColumnType(columnType))
+	})
+}
+
+func (es *Esui) addColumn(ctx context.Context, projectionID ShortID,
+	tableName string, columnName string, columnType ColumnType) (err error) {
+	projection, err := es.loadInactiveProjection(ctx, projectionID)
 	if err != nil {
-		logger.Println(ctx, err)
 		return
 	}
-	if projection.Name == "" {
-		err = errors.New("projection not found")
+
+	err = columnType.Validate()
+	if err != nil {
 		logger.Println(ctx, err)
 		return
 	}
@@ -372,46 +575,24 @@ func (es *Esui) AddColumn(ctx context.Context, projectionID ShortID,
 		projection.Tables = make(map[string]EsuiTable)
 	}
 
-	if _, ok := projection.Tables[tableName]; !ok {
-		err = errors.New("table not found")
+	table, ok := projection.Tables[tableName]
+	if !ok {
+		err = ErrTableNotFound
 		logger.Println(ctx, err)
 		return
 	}
 
-	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "column_added", EsuiColumnAdded{
-		TableName:  tableName,
-		ColumnName: columnName,
-		ColumnType: columnType,
-	})
-
-	return
-}
-
-type Block struct {
-	BlockID      string    `json:"block_id"`
-	Name         string    `json:"name"`
-	Type         string    `json:"type"`
-	OrderedAfter string    `json:"ordered_after"`
-	Data         BlockData `json:"data"`
-}
-
-type BlockData struct {
-	Javascript *string `json:"javascript"`
-}
-
-func (es *Esui) AddBlock(ctx context.Context, projectionID ShortID, data Block) (err error) {
-	projection, err := es.GetProjection(ctx, projectionID)
-	if err != nil {
-		logger.Println(ctx, err)
-		return
-	}
-	if projection.Name == "" {
-		err = errors.New("projection not found")
+	if _, ok := table.Columns[columnName]; ok {
+		err = ErrColumnExists
 		logger.Println(ctx, err)
 		return
 	}
 
-	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "block_added", data)
+	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "column_added", EsuiColumnAdded{
+		TableName:  tableName,
+		ColumnName: columnName,
+		ColumnType: columnType,
+	}, projection.Version)
 
 	return
 }
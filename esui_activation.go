@@ -0,0 +1,108 @@
+package esui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ariefsam/esui/logger"
+	"github.com/dop251/goja/parser"
+)
+
+var (
+	ErrInvalidProjection = errors.New("invalid projection")
+	ErrAlreadyActive     = errors.New("projection already active")
+	ErrNotActive         = errors.New("projection not active")
+	ErrProjectionActive  = errors.New("projection is active, deactivate it first")
+)
+
+// Validate reports every reason the projection is not ready to run: it has
+// no table, a table has a column without a valid type, or its blocks are not
+// ordered, carry javascript under another type or do not parse. The returned
+// error matches ErrInvalidProjection.
+func (projection EsuiProjection) Validate() (err error) {
+	var errs []error
+	if len(projection.Tables) == 0 {
+		errs = append(errs, errors.New("no table"))
+	}
+	for _, table := range projection.Tables {
+		for _, column := range table.Columns {
+			if typeErr := column.Type.Validate(); typeErr != nil {
+				errs = append(errs, fmt.Errorf("column %s.%s: %w", table.Name, column.Name, typeErr))
+			}
+		}
+	}
+
+	if _, orderErr := projection.OrderedBlocks(); orderErr != nil {
+		errs = append(errs, orderErr)
+	}
+	for _, block := range projection.Blocks {
+		if block.Data.Javascript == nil {
+			continue
+		}
+		if block.Type != BlockTypeJavascript {
+			errs = append(errs, fmt.Errorf("block %s: %w: %q", block.BlockID, ErrBlockType, block.Type))
+			continue
+		}
+		if _, parseErr := parser.ParseFile(nil, block.BlockID, block.Function(), 0); parseErr != nil {
+			errs = append(errs, fmt.Errorf("block %s: %w", block.BlockID, parseErr))
+		}
+	}
+
+	if len(errs) > 0 {
+		err = fmt.Errorf("%w: %w", ErrInvalidProjection, errors.Join(errs...))
+	}
+	return
+}
+
+// loadInactiveProjection loads a projection for a change to its tables,
+// blocks or subscriptions. An active projection was validated when it was
+// activated and is being run, so it only changes after DeactivateProjection.
+func (es *Esui) loadInactiveProjection(ctx context.Context, projectionID ShortID) (projection EsuiProjection, err error) {
+	projection, err = es.loadProjection(ctx, projectionID)
+	if err != nil {
+		return
+	}
+	if projection.IsActive {
+		err = fmt.Errorf("%w: %s", ErrProjectionActive, projectionID)
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// ActivateProjection marks a projection as finished so it is run. It is
+// rejected when Validate fails.
+func (es *Esui) ActivateProjection(ctx context.Context, projectionID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadProjection(ctx, projectionID)
+		if err != nil {
+			return
+		}
+		if projection.IsActive {
+			err = ErrAlreadyActive
+			logger.Println(ctx, err)
+			return
+		}
+		err = projection.Validate()
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "activated", struct{}{}, projection.Version)
+	})
+}
+
+func (es *Esui) DeactivateProjection(ctx context.Context, projectionID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadProjection(ctx, projectionID)
+		if err != nil {
+			return
+		}
+		if !projection.IsActive {
+			err = ErrNotActive
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "deactivated", struct{}{}, projection.Version)
+	})
+}
@@ -1,5 +1,19 @@
 package esui
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var (
+	ErrApplicationNotFound = errors.New("application not found")
+	ErrAlreadyAttached     = errors.New("already attached to application")
+)
+
 type Application struct {
 	ID          ShortID
 	Name        string
@@ -34,3 +48,286 @@ type Table struct {
 	Name    string
 	Columns map[AttributeName]AttributeType
 }
+
+// EsuiApplication is the replayed application aggregate. It only references
+// its entities and projections, GetApplication resolves them.
+type EsuiApplication struct {
+	ID          ShortID          `json:"application_id"`
+	Name        string           `json:"name"`
+	Entities    map[ShortID]bool `json:"entities"`
+	Projections map[ShortID]bool `json:"projections"`
+	// Releases lists the published version IDs, oldest first. The same ID
+	// appears again when an older content is published again.
+	Releases []ShortID `json:"releases"`
+	Version  int64     `json:"version"`
+}
+
+type EsuiApplicationCreated struct {
+	Name string `json:"name"`
+}
+
+type EsuiEntityAttached struct {
+	EntityID ShortID `json:"entity_id"`
+}
+
+type EsuiProjectionAttached struct {
+	ProjectionID ShortID `json:"projection_id"`
+}
+
+func (es *Esui) CreateApplication(ctx context.Context, applicationName string) (applicationID ShortID, err error) {
+	if applicationName == "" {
+		err = ErrEmptyName
+		logger.Println(ctx, err)
+		return
+	}
+	applicationID = ShortID(es.idgenerator.Generate())
+	err = es.eventstore.StoreEvent(ctx, string(applicationID), "application", "created", EsuiApplicationCreated{
+		Name: applicationName,
+	}, 0)
+	if err != nil {
+		logger.Println(ctx, err)
+		return "", err
+	}
+	return
+}
+
+// AttachEntity adds an entity to the application. Archived entities cannot
+// be attached.
+func (es *Esui) AttachEntity(ctx context.Context, applicationID ShortID, entityID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		application, err := es.loadApplication(ctx, applicationID)
+		if err != nil {
+			return
+		}
+		_, err = es.loadEntity(ctx, entityID)
+		if err != nil {
+			return
+		}
+		if application.Entities[entityID] {
+			err = ErrAlreadyAttached
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(applicationID), "application", "entity_attached", EsuiEntityAttached{
+			EntityID: entityID,
+		}, application.Version)
+	})
+}
+
+// AttachProjection adds a projection to the application the same way
+// AttachEntity does.
+func (es *Esui) AttachProjection(ctx context.Context, applicationID ShortID, projectionID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		application, err := es.loadApplication(ctx, applicationID)
+		if err != nil {
+			return
+		}
+		_, err = es.loadProjection(ctx, projectionID)
+		if err != nil {
+			return
+		}
+		if application.Projections[projectionID] {
+			err = ErrAlreadyAttached
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(applicationID), "application", "projection_attached", EsuiProjectionAttached{
+			ProjectionID: projectionID,
+		}, application.Version)
+	})
+}
+
+// getApplication replays the application aggregate without resolving it.
+func (es *Esui) getApplication(ctx context.Context, applicationID ShortID) (application EsuiApplication, err error) {
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(applicationID), "application", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	for _, event := range events {
+		switch event.EventName {
+		case "created":
+			application.HandleCreated(event, applicationID)
+		case "entity_attached":
+			application.HandleEntityAttached(event)
+		case "projection_attached":
+			application.HandleProjectionAttached(event)
+		case "version_published":
+			application.HandleVersionPublished(event)
+		}
+	}
+	application.Version = int64(len(events))
+	return
+}
+
+// loadApplication is getApplication for commands, see loadEntity.
+func (es *Esui) loadApplication(ctx context.Context, applicationID ShortID) (application EsuiApplication, err error) {
+	application, err = es.getApplication(ctx, applicationID)
+	if err != nil {
+		return
+	}
+	if application.Name == "" {
+		err = ErrApplicationNotFound
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// GetApplication replays the application and resolves its entities and
+// projections into their current definitions. Version is the version
+// published last, the definitions may have changed since.
+func (es *Esui) GetApplication(ctx context.Context, applicationID ShortID) (application Application, err error) {
+	esuiApplication, err := es.getApplication(ctx, applicationID)
+	if err != nil {
+		return
+	}
+	if esuiApplication.Name == "" {
+		return
+	}
+
+	entities, projections, err := es.definitions(ctx, esuiApplication)
+	if err != nil {
+		return
+	}
+	application = resolveApplication(esuiApplication, entities, projections)
+	application.Version = esuiApplication.LatestVersion()
+	return
+}
+
+// definitions replays the current definitions of the application's entities
+// and projections.
+func (es *Esui) definitions(ctx context.Context, esuiApplication EsuiApplication) (entities map[ShortID]EsuiEntity, projections map[ShortID]EsuiProjection, err error) {
+	entities = make(map[ShortID]EsuiEntity)
+	for entityID := range esuiApplication.Entities {
+		var entity EsuiEntity
+		entity, err = es.GetEntity(ctx, entityID)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		entities[entityID] = entity
+	}
+	projections = make(map[ShortID]EsuiProjection)
+	for projectionID := range esuiApplication.Projections {
+		var projection EsuiProjection
+		projection, err = es.GetProjection(ctx, projectionID)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		projections[projectionID] = projection
+	}
+	return
+}
+
+func resolveApplication(esuiApplication EsuiApplication, entities map[ShortID]EsuiEntity, projections map[ShortID]EsuiProjection) (application Application) {
+	application = Application{
+		ID:          esuiApplication.ID,
+		Name:        esuiApplication.Name,
+		Entity:      make(map[EntityID]Entity),
+		Projections: make(map[ProjectionID]Projection),
+	}
+	for entityID, entity := range entities {
+		application.Entity[EntityID(entityID)] = entity.resolve()
+	}
+	for projectionID, projection := range projections {
+		application.Projections[ProjectionID(projectionID)] = projection.resolve()
+	}
+	return
+}
+
+func (entity EsuiEntity) resolve() Entity {
+	resolved := Entity{
+		Name:   entity.Name,
+		Events: make(map[ShortID]Event),
+	}
+	for name, event := range entity.Events {
+		attributes := make(map[AttributeName]AttributeType)
+		for attributeName, attributeType := range event.Attributes {
+			attributes[attributeName] = attributeType
+		}
+		resolved.Events[ShortID(name)] = Event{
+			Name:      name,
+			Attribute: attributes,
+		}
+	}
+	return resolved
+}
+
+// resolve lists the tables by name and the blocks in execution order. Blocks
+// that cannot be ordered are listed as stored.
+func (projection EsuiProjection) resolve() Projection {
+	resolved := Projection{
+		ID:          projection.ID,
+		Name:        projection.Name,
+		SubscribeTo: make(map[EntityID]map[EntityEventName]bool),
+	}
+	for entityID, eventNames := range projection.Subscriptions {
+		events := make(map[EntityEventName]bool)
+		for eventName := range eventNames {
+			events[EntityEventName(eventName)] = true
+		}
+		resolved.SubscribeTo[EntityID(entityID)] = events
+	}
+
+	tableNames := make([]string, 0, len(projection.Tables))
+	for name := range projection.Tables {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+	for _, name := range tableNames {
+		table := Table{
+			Name:    name,
+			Columns: make(map[AttributeName]AttributeType),
+		}
+		for columnName, column := range projection.Tables[name].Columns {
+			table.Columns[AttributeName(columnName)] = AttributeType(column.Type)
+		}
+		resolved.Tables = append(resolved.Tables, table)
+	}
+
+	blocks, err := projection.OrderedBlocks()
+	if err != nil {
+		blocks = projection.Blocks
+	}
+	resolved.Blocks = append([]Block(nil), blocks...)
+	return resolved
+}
+
+func (application *EsuiApplication) HandleCreated(event EstoreEvent, applicationID ShortID) {
+	var created EsuiApplicationCreated
+	err := json.Unmarshal([]byte(event.Data), &created)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	application.ID = applicationID
+	application.Name = created.Name
+}
+
+func (application *EsuiApplication) HandleEntityAttached(event EstoreEvent) {
+	var attached EsuiEntityAttached
+	err := json.Unmarshal([]byte(event.Data), &attached)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	if application.Entities == nil {
+		application.Entities = make(map[ShortID]bool)
+	}
+	application.Entities[attached.EntityID] = true
+}
+
+func (application *EsuiApplication) HandleProjectionAttached(event EstoreEvent) {
+	var attached EsuiProjectionAttached
+	err := json.Unmarshal([]byte(event.Data), &attached)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	if application.Projections == nil {
+		application.Projections = make(map[ShortID]bool)
+	}
+	application.Projections[attached.ProjectionID] = true
+}
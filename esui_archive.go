@@ -0,0 +1,85 @@
+package esui
+
+import (
+	"context"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+// ArchiveEntity retires an entity. Its history is kept and it can still be
+// read, but every command refuses to change it until RestoreEntity. It fails
+// with ErrEventInUse while a projection subscribes to one of its events.
+func (es *Esui) ArchiveEntity(ctx context.Context, entityID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		entity, err := es.loadEntity(ctx, entityID)
+		if err != nil {
+			return
+		}
+		for eventName := range entity.Events {
+			err = es.checkEventNotInUse(ctx, entityID, eventName)
+			if err != nil {
+				return
+			}
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "archived", struct{}{}, entity.Version)
+	})
+}
+
+// RestoreEntity fails with ErrEntityExists when another entity took the name
+// while it was archived.
+func (es *Esui) RestoreEntity(ctx context.Context, entityID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		entity, err := es.GetEntity(ctx, entityID)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		if entity.Name == "" {
+			err = ErrEntityNotFound
+			logger.Println(ctx, err)
+			return
+		}
+		if !entity.Archived {
+			err = ErrNotArchived
+			logger.Println(ctx, err)
+			return
+		}
+		err = es.checkEntityNameFree(ctx, entityID, entity.Name)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "restored", struct{}{}, entity.Version)
+	})
+}
+
+// ArchiveProjection retires a projection the same way ArchiveEntity does.
+func (es *Esui) ArchiveProjection(ctx context.Context, projectionID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadProjection(ctx, projectionID)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "archived", struct{}{}, projection.Version)
+	})
+}
+
+func (es *Esui) RestoreProjection(ctx context.Context, projectionID ShortID) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.GetProjection(ctx, projectionID)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		if projection.Name == "" {
+			err = ErrProjectionNotFound
+			logger.Println(ctx, err)
+			return
+		}
+		if !projection.Archived {
+			err = ErrNotArchived
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "restored", struct{}{}, projection.Version)
+	})
+}
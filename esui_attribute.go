@@ -0,0 +1,203 @@
+package esui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+type AttributeName string
+type AttributeType string
+
+const (
+	TypeString   AttributeType = "string"
+	TypeInt      AttributeType = "int"
+	TypeFloat    AttributeType = "float"
+	TypeBool     AttributeType = "bool"
+	TypeDatetime AttributeType = "datetime"
+	TypeDecimal  AttributeType = "decimal"
+	TypeEnum     AttributeType = "enum"
+	TypeArray    AttributeType = "array"
+	TypeObject   AttributeType = "object"
+)
+
+func (atype AttributeType) Validate() error {
+	switch atype {
+	case TypeString, TypeInt, TypeFloat, TypeBool, TypeDatetime, TypeDecimal, TypeEnum, TypeArray, TypeObject:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidType, atype)
+}
+
+// AttributeSchema fully describes an attribute. Values is only used by enum,
+// Items by array and Fields by object. Default, when set, is the JSON
+// encoded value used when a payload leaves the attribute out.
+type AttributeSchema struct {
+	Type     AttributeType                     `json:"type"`
+	Values   []string                          `json:"values,omitempty"`
+	Items    *AttributeSchema                  `json:"items,omitempty"`
+	Fields   map[AttributeName]AttributeSchema `json:"fields,omitempty"`
+	Nullable bool                              `json:"nullable,omitempty"`
+	Required bool                              `json:"required,omitempty"`
+	Default  json.RawMessage                   `json:"default,omitempty"`
+}
+
+func (schema AttributeSchema) Validate() (err error) {
+	err = schema.Type.Validate()
+	if err != nil {
+		return
+	}
+
+	switch schema.Type {
+	case TypeEnum:
+		if len(schema.Values) == 0 {
+			return fmt.Errorf("%w: enum without values", ErrInvalidType)
+		}
+		seen := make(map[string]bool)
+		for _, value := range schema.Values {
+			if seen[value] {
+				return fmt.Errorf("%w: duplicate enum value %s", ErrInvalidType, value)
+			}
+			seen[value] = true
+		}
+	case TypeArray:
+		if schema.Items == nil {
+			return fmt.Errorf("%w: array without items", ErrInvalidType)
+		}
+		err = schema.Items.Validate()
+		if err != nil {
+			return
+		}
+	case TypeObject:
+		if len(schema.Fields) == 0 {
+			return fmt.Errorf("%w: object without fields", ErrInvalidType)
+		}
+		for name, field := range schema.Fields {
+			err = field.Validate()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	if len(schema.Default) > 0 {
+		err = schema.CheckValue(schema.Default)
+		if err != nil {
+			return fmt.Errorf("%w: default: %v", ErrInvalidType, err)
+		}
+	}
+	return
+}
+
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// CheckValue reports whether raw, a JSON encoded value, matches the schema.
+func (schema AttributeSchema) CheckValue(raw json.RawMessage) (err error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	err = decoder.Decode(&value)
+	if err != nil {
+		return
+	}
+	return schema.checkValue(value)
+}
+
+func (schema AttributeSchema) checkValue(value interface{}) error {
+	if value == nil {
+		if schema.Nullable {
+			return nil
+		}
+		return fmt.Errorf("null is not allowed")
+	}
+
+	switch schema.Type {
+	case TypeString:
+		if _, ok := value.(string); ok {
+			return nil
+		}
+	case TypeInt:
+		if number, ok := value.(json.Number); ok {
+			if _, err := number.Int64(); err == nil {
+				return nil
+			}
+		}
+	case TypeFloat:
+		if _, ok := value.(json.Number); ok {
+			return nil
+		}
+	case TypeBool:
+		if _, ok := value.(bool); ok {
+			return nil
+		}
+	case TypeDatetime:
+		if text, ok := value.(string); ok {
+			if _, err := time.Parse(time.RFC3339, text); err == nil {
+				return nil
+			}
+		}
+	case TypeDecimal:
+		switch decimal := value.(type) {
+		case string:
+			if decimalPattern.MatchString(decimal) {
+				return nil
+			}
+		case json.Number:
+			if decimalPattern.MatchString(decimal.String()) {
+				return nil
+			}
+		}
+	case TypeEnum:
+		if text, ok := value.(string); ok {
+			for _, allowed := range schema.Values {
+				if text == allowed {
+					return nil
+				}
+			}
+			return fmt.Errorf("%q is not one of %v", text, schema.Values)
+		}
+	case TypeArray:
+		if items, ok := value.([]interface{}); ok {
+			for i, item := range items {
+				if err := schema.Items.checkValue(item); err != nil {
+					return fmt.Errorf("[%d]: %w", i, err)
+				}
+			}
+			return nil
+		}
+	case TypeObject:
+		if object, ok := value.(map[string]interface{}); ok {
+			return schema.checkFields(object)
+		}
+	}
+	return fmt.Errorf("expected %s, got %v", schema.Type, value)
+}
+
+// mustProvide tells whether a payload leaving the attribute out is rejected.
+// A default fills in for a missing required attribute.
+func mustProvide(schema AttributeSchema) bool {
+	return schema.Required && len(schema.Default) == 0
+}
+
+func (schema AttributeSchema) checkFields(object map[string]interface{}) error {
+	for name := range object {
+		if _, ok := schema.Fields[AttributeName(name)]; !ok {
+			return fmt.Errorf("unknown field %s", name)
+		}
+	}
+	for name, field := range schema.Fields {
+		value, ok := object[string(name)]
+		if !ok {
+			if mustProvide(field) {
+				return fmt.Errorf("%s: required", name)
+			}
+			continue
+		}
+		if err := field.checkValue(value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package esui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var (
+	ErrBlockNotFound  = errors.New("block not found")
+	ErrBlockExists    = errors.New("block already exist")
+	ErrEmptyBlockID   = errors.New("block id is empty")
+	ErrBlockCycle     = errors.New("block order has a cycle")
+	ErrBlockDangling  = errors.New("block ordered after unknown block")
+	ErrBlockDuplicate = errors.New("duplicate block id")
+	ErrBlockType      = errors.New("block with javascript is not of type javascript")
+)
+
+// BlockTypeJavascript is the type of the blocks the runtime runs.
+const BlockTypeJavascript = "javascript"
+
+type Block struct {
+	BlockID      string    `json:"block_id"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	OrderedAfter string    `json:"ordered_after"`
+	Data         BlockData `json:"data"`
+}
+
+type BlockData struct {
+	Javascript *string `json:"javascript"`
+}
+
+// Function returns the javascript of the block as the function of (event,
+// table) that the runtime calls, so a top level return is accepted.
+func (block Block) Function() string {
+	source := ""
+	if block.Data.Javascript != nil {
+		source = *block.Data.Javascript
+	}
+	return "(function(event, table) {\n" + source + "\n})"
+}
+
+// AddBlock puts the block directly after data.OrderedAfter, or first when it
+// is empty, the same way MoveBlock places a block. The block that followed
+// OrderedAfter now follows the added block.
+func (es *Esui) AddBlock(ctx context.Context, projectionID ShortID, data Block) (err error) {
+	return es.retry(ctx, func() error {
+		return es.addBlock(ctx, projectionID, data)
+	})
+}
+
+func (es *Esui) addBlock(ctx context.Context, projectionID ShortID, data Block) (err error) {
+	projection, err := es.loadInactiveProjection(ctx, projectionID)
+	if err != nil {
+		return
+	}
+
+	if data.BlockID == "" {
+		err = ErrEmptyBlockID
+		logger.Println(ctx, err)
+		return
+	}
+
+	if _, ok := projection.block(data.BlockID); ok {
+		err = ErrBlockExists
+		logger.Println(ctx, err)
+		return
+	}
+
+	if _, ok := projection.block(data.OrderedAfter); data.OrderedAfter != "" && !ok {
+		err = fmt.Errorf("%w: %s", ErrBlockNotFound, data.OrderedAfter)
+		logger.Println(ctx, err)
+		return
+	}
+
+	err = es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "block_added", data, projection.Version)
+
+	return
+}
+
+func (projection *EsuiProjection) HandleBlockAdded(event EstoreEvent) {
+	var block Block
+	err := json.Unmarshal([]byte(event.Data), &block)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	projection.Blocks = append(projection.Blocks, block)
+	projection.link(len(projection.Blocks)-1, block.OrderedAfter)
+}
+
+// block returns the first block with the id.
+func (projection EsuiProjection) block(blockID string) (block Block, ok bool) {
+	for _, block := range projection.Blocks {
+		if block.BlockID == blockID {
+			return block, true
+		}
+	}
+	return
+}
+
+// OrderedBlocks follows the OrderedAfter links starting from the blocks with
+// an empty OrderedAfter. Blocks ordered after the same block keep the order
+// they were added in, each followed by its own successors. Blocks that cannot
+// be reached are reported as dangling or cyclic and left out, as are
+// duplicate ids after their first occurrence.
+func (projection EsuiProjection) OrderedBlocks() (blocks []Block, err error) {
+	var errs []error
+	byID := make(map[string]Block)
+	first := make(map[string]int)
+	successors := make(map[string][]string)
+	for i, block := range projection.Blocks {
+		if _, ok := byID[block.BlockID]; ok {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrBlockDuplicate, block.BlockID))
+			continue
+		}
+		byID[block.BlockID] = block
+		first[block.BlockID] = i
+		successors[block.OrderedAfter] = append(successors[block.OrderedAfter], block.BlockID)
+	}
+
+	visited := make(map[string]bool)
+	var visit func(blockID string)
+	visit = func(blockID string) {
+		for _, next := range successors[blockID] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			blocks = append(blocks, byID[next])
+			visit(next)
+		}
+	}
+	visit("")
+
+	for i, block := range projection.Blocks {
+		if visited[block.BlockID] || first[block.BlockID] != i {
+			continue
+		}
+		if _, ok := byID[block.OrderedAfter]; !ok {
+			errs = append(errs, fmt.Errorf("%w: %s after %s", ErrBlockDangling, block.BlockID, block.OrderedAfter))
+			continue
+		}
+		// Walk back towards the head. Reaching a dangling block means this
+		// one is only unreachable because of it, coming back means a cycle.
+		seen := map[string]bool{block.BlockID: true}
+		current := block.OrderedAfter
+		for {
+			previous, ok := byID[current]
+			if !ok {
+				break
+			}
+			if seen[current] {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrBlockCycle, block.BlockID))
+				break
+			}
+			seen[current] = true
+			current = previous.OrderedAfter
+		}
+	}
+
+	err = errors.Join(errs...)
+	return
+}
+
+type EsuiBlockMoved struct {
+	BlockID      string `json:"block_id"`
+	OrderedAfter string `json:"ordered_after"`
+}
+
+type EsuiBlockRemoved struct {
+	BlockID string `json:"block_id"`
+}
+
+type EsuiBlockDuplicated struct {
+	BlockID    string `json:"block_id"`
+	NewBlockID string `json:"new_block_id"`
+}
+
+// loadBlock loads the projection and checks that it has the block.
+func (es *Esui) loadBlock(ctx context.Context, projectionID ShortID, blockID string) (projection EsuiProjection, err error) {
+	projection, err = es.loadInactiveProjection(ctx, projectionID)
+	if err != nil {
+		return
+	}
+	if _, ok := projection.block(blockID); !ok {
+		err = fmt.Errorf("%w: %s", ErrBlockNotFound, blockID)
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// UpdateBlock replaces the name, type and data of a block. Its position is
+// changed with MoveBlock only, so data.OrderedAfter is ignored.
+func (es *Esui) UpdateBlock(ctx context.Context, projectionID ShortID, data Block) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadBlock(ctx, projectionID, data.BlockID)
+		if err != nil {
+			return
+		}
+		data.OrderedAfter = ""
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "block_updated", data, projection.Version)
+	})
+}
+
+// MoveBlock puts a block directly after orderedAfter, or first when it is
+// empty. The blocks that followed it close the gap it leaves behind and the
+// block that followed orderedAfter now follows the moved block.
+func (es *Esui) MoveBlock(ctx context.Context, projectionID ShortID, blockID string, orderedAfter string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadBlock(ctx, projectionID, blockID)
+		if err != nil {
+			return
+		}
+		if orderedAfter == blockID {
+			err = fmt.Errorf("%w: %s", ErrBlockCycle, blockID)
+			logger.Println(ctx, err)
+			return
+		}
+		if _, ok := projection.block(orderedAfter); orderedAfter != "" && !ok {
+			err = fmt.Errorf("%w: %s", ErrBlockNotFound, orderedAfter)
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "block_moved", EsuiBlockMoved{
+			BlockID:      blockID,
+			OrderedAfter: orderedAfter,
+		}, projection.Version)
+	})
+}
+
+// RemoveBlock deletes a block, the blocks that followed it move up.
+func (es *Esui) RemoveBlock(ctx context.Context, projectionID ShortID, blockID string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadBlock(ctx, projectionID, blockID)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "block_removed", EsuiBlockRemoved{
+			BlockID: blockID,
+		}, projection.Version)
+	})
+}
+
+// DuplicateBlock copies a block under a new id directly after the original.
+func (es *Esui) DuplicateBlock(ctx context.Context, projectionID ShortID, blockID string) (newBlockID string, err error) {
+	newBlockID = es.idgenerator.Generate()
+	err = es.retry(ctx, func() (err error) {
+		projection, err := es.loadBlock(ctx, projectionID, blockID)
+		if err != nil {
+			return
+		}
+		if _, ok := projection.block(newBlockID); ok {
+			err = fmt.Errorf("%w: %s", ErrBlockExists, newBlockID)
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "block_duplicated", EsuiBlockDuplicated{
+			BlockID:    blockID,
+			NewBlockID: newBlockID,
+		}, projection.Version)
+	})
+	if err != nil {
+		return "", err
+	}
+	return
+}
+
+func (projection *EsuiProjection) blockIndex(blockID string) int {
+	for i, block := range projection.Blocks {
+		if block.BlockID == blockID {
+			return i
+		}
+	}
+	return -1
+}
+
+// unlink takes the block at index out of the order, the blocks that followed
+// it follow its predecessor instead.
+func (projection *EsuiProjection) unlink(index int) {
+	removed := projection.Blocks[index]
+	for i := range projection.Blocks {
+		if i != index && projection.Blocks[i].OrderedAfter == removed.BlockID {
+			projection.Blocks[i].OrderedAfter = removed.OrderedAfter
+		}
+	}
+}
+
+// link puts the block at index directly after orderedAfter, the blocks that
+// followed orderedAfter follow the block instead.
+func (projection *EsuiProjection) link(index int, orderedAfter string) {
+	blockID := projection.Blocks[index].BlockID
+	for i := range projection.Blocks {
+		if i != index && projection.Blocks[i].OrderedAfter == orderedAfter {
+			projection.Blocks[i].OrderedAfter = blockID
+		}
+	}
+	projection.Blocks[index].OrderedAfter = orderedAfter
+}
+
+func (projection *EsuiProjection) HandleBlockUpdated(event EstoreEvent) {
+	var updated Block
+	err := json.Unmarshal([]byte(event.Data), &updated)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	index := projection.blockIndex(updated.BlockID)
+	if index < 0 {
+		logger.Println(ErrBlockNotFound)
+		return
+	}
+	updated.OrderedAfter = projection.Blocks[index].OrderedAfter
+	projection.Blocks[index] = updated
+}
+
+func (projection *EsuiProjection) HandleBlockMoved(event EstoreEvent) {
+	var moved EsuiBlockMoved
+	err := json.Unmarshal([]byte(event.Data), &moved)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	index := projection.blockIndex(moved.BlockID)
+	if index < 0 {
+		logger.Println(ErrBlockNotFound)
+		return
+	}
+	projection.unlink(index)
+	projection.link(index, moved.OrderedAfter)
+}
+
+func (projection *EsuiProjection) HandleBlockRemoved(event EstoreEvent) {
+	var removed EsuiBlockRemoved
+	err := json.Unmarshal([]byte(event.Data), &removed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	index := projection.blockIndex(removed.BlockID)
+	if index < 0 {
+		logger.Println(ErrBlockNotFound)
+		return
+	}
+	projection.unlink(index)
+	projection.Blocks = append(projection.Blocks[:index], projection.Blocks[index+1:]...)
+}
+
+func (projection *EsuiProjection) HandleBlockDuplicated(event EstoreEvent) {
+	var duplicated EsuiBlockDuplicated
+	err := json.Unmarshal([]byte(event.Data), &duplicated)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	index := projection.blockIndex(duplicated.BlockID)
+	if index < 0 {
+		logger.Println(ErrBlockNotFound)
+		return
+	}
+	block := projection.Blocks[index]
+	block.BlockID = duplicated.NewBlockID
+	if block.Data.Javascript != nil {
+		javascript := *block.Data.Javascript
+		block.Data.Javascript = &javascript
+	}
+	projection.Blocks = append(projection.Blocks, block)
+	projection.link(len(projection.Blocks)-1, duplicated.BlockID)
+}
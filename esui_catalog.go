@@ -0,0 +1,172 @@
+package esui
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+type CatalogItem struct {
+	ID       ShortID `json:"id"`
+	Name     string  `json:"name"`
+	Position int64   `json:"position"`
+	Archived bool    `json:"archived"`
+}
+
+const (
+	SortByPosition = "position"
+	SortByName     = "name"
+)
+
+type ListOptions struct {
+	// NameContains keeps only items whose name contains it, ignoring case.
+	NameContains string
+	// SortBy is SortByPosition (creation order, the default) or SortByName.
+	SortBy     string
+	Descending bool
+	Offset     int
+	// Limit of zero or less returns every remaining item.
+	Limit int
+	// IncludeArchived also lists archived items, they are hidden by default.
+	IncludeArchived bool
+}
+
+// Catalog is a read model of every entity and projection. Feed it with
+// Handle, usually through a Subscription, and query it with the List and
+// Find methods.
+type Catalog struct {
+	mu          sync.RWMutex
+	entities    map[ShortID]CatalogItem
+	projections map[ShortID]CatalogItem
+}
+
+func NewCatalog() (obj *Catalog) {
+	obj = &Catalog{
+		entities:    make(map[ShortID]CatalogItem),
+		projections: make(map[ShortID]CatalogItem),
+	}
+	return obj
+}
+
+func (c *Catalog) Handle(ctx context.Context, event EstoreEvent) (err error) {
+	var items map[ShortID]CatalogItem
+	switch event.AggregateName {
+	case "entity":
+		items = c.entities
+	case "projection":
+		items = c.projections
+	default:
+		return
+	}
+
+	switch event.EventName {
+	case "created", "renamed":
+		// Both carry just the name, for entities and projections alike.
+		var created EsuiEntityCreated
+		err = json.Unmarshal([]byte(event.Data), &created)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		item, ok := items[event.AggregateID]
+		if !ok {
+			item = CatalogItem{
+				ID:       event.AggregateID,
+				Position: event.Position,
+			}
+		}
+		item.Name = created.Name
+		items[event.AggregateID] = item
+	case "archived", "restored":
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		item, ok := items[event.AggregateID]
+		if !ok {
+			return
+		}
+		item.Archived = event.EventName == "archived"
+		items[event.AggregateID] = item
+	}
+	return
+}
+
+func (c *Catalog) ListEntities(options ListOptions) (items []CatalogItem, total int) {
+	return c.list(c.entities, options)
+}
+
+func (c *Catalog) ListProjections(options ListOptions) (items []CatalogItem, total int) {
+	return c.list(c.projections, options)
+}
+
+func (c *Catalog) FindEntityByName(name string) (item CatalogItem, err error) {
+	item, ok := c.findByName(c.entities, name)
+	if !ok {
+		err = ErrEntityNotFound
+	}
+	return
+}
+
+func (c *Catalog) FindProjectionByName(name string) (item CatalogItem, err error) {
+	item, ok := c.findByName(c.projections, name)
+	if !ok {
+		err = ErrProjectionNotFound
+	}
+	return
+}
+
+// list filters and sorts items, then returns the requested page and the
+// number of items that matched before paging.
+func (c *Catalog) list(source map[ShortID]CatalogItem, options ListOptions) (items []CatalogItem, total int) {
+	c.mu.RLock()
+	filter := strings.ToLower(options.NameContains)
+	matched := []CatalogItem{}
+	for _, item := range source {
+		if item.Archived && !options.IncludeArchived {
+			continue
+		}
+		if strings.Contains(strings.ToLower(item.Name), filter) {
+			matched = append(matched, item)
+		}
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if options.Descending {
+			a, b = b, a
+		}
+		if options.SortBy == SortByName && a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Position < b.Position
+	})
+
+	total = len(matched)
+	start := min(max(options.Offset, 0), total)
+	end := total
+	if options.Limit > 0 && start+options.Limit < end {
+		end = start + options.Limit
+	}
+	items = matched[start:end]
+	return
+}
+
+// findByName returns the earliest created item with exactly this name that
+// is not archived.
+func (c *Catalog) findByName(source map[ShortID]CatalogItem, name string) (item CatalogItem, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, candidate := range source {
+		if candidate.Name == name && !candidate.Archived && (!ok || candidate.Position < item.Position) {
+			item, ok = candidate, true
+		}
+	}
+	return
+}
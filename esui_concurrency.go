@@ -0,0 +1,66 @@
+package esui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+// AnyVersion skips the optimistic concurrency check in StoreEvent.
+const AnyVersion int64 = -1
+
+var ErrConcurrency = errors.New("concurrency conflict")
+
+// ConcurrencyError is returned by an eventstore when the aggregate moved past
+// the version the caller expected. It matches ErrConcurrency with errors.Is.
+type ConcurrencyError struct {
+	AggregateID     string
+	AggregateName   string
+	ExpectedVersion int64
+	ActualVersion   int64
+}
+
+func (e *ConcurrencyError) Error() string {
+	return fmt.Sprintf("concurrency conflict on %s %s: expected version %d, actual version %d",
+		e.AggregateName, e.AggregateID, e.ExpectedVersion, e.ActualVersion)
+}
+
+func (e *ConcurrencyError) Is(target error) bool {
+	return target == ErrConcurrency
+}
+
+// RetryPolicy controls how often a command is re-run after a concurrency
+// conflict. The zero value does not retry.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+type Option func(*Esui)
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(es *Esui) {
+		es.retryPolicy = policy
+	}
+}
+
+// retry runs command until it succeeds, fails with anything other than a
+// concurrency conflict, or runs out of attempts.
+func (es *Esui) retry(ctx context.Context, command func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = command()
+		if !errors.Is(err, ErrConcurrency) || attempt >= es.retryPolicy.MaxAttempts {
+			return
+		}
+		logger.Println(ctx, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(es.retryPolicy.Backoff * time.Duration(attempt)):
+		}
+	}
+}
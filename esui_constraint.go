@@ -0,0 +1,264 @@
+package esui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var ErrInvalidConstraint = errors.New("invalid attribute constraint")
+
+// AttributeConstraint narrows the values an attribute accepts beyond its
+// type. Lengths apply to strings and arrays, Min and Max to int, float and
+// decimal attributes, Pattern to strings.
+type AttributeConstraint struct {
+	MinLength *int     `json:"min_length,omitempty"`
+	MaxLength *int     `json:"max_length,omitempty"`
+	Min       *float64 `json:"min,omitempty"`
+	Max       *float64 `json:"max,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Required  bool     `json:"required,omitempty"`
+}
+
+type EsuiAttributeConstraintSet struct {
+	EventName  string              `json:"event_name"`
+	Name       AttributeName       `json:"name"`
+	Constraint AttributeConstraint `json:"constraint"`
+}
+
+type Violation struct {
+	Attribute AttributeName `json:"attribute"`
+	Message   string        `json:"message"`
+}
+
+func (constraint AttributeConstraint) validate(schema AttributeSchema) error {
+	hasLength := constraint.MinLength != nil || constraint.MaxLength != nil
+	if hasLength && schema.Type != TypeString && schema.Type != TypeArray {
+		return fmt.Errorf("%w: length on %s", ErrInvalidConstraint, schema.Type)
+	}
+	if hasLength && constraint.MinLength != nil && constraint.MaxLength != nil && *constraint.MinLength > *constraint.MaxLength {
+		return fmt.Errorf("%w: min length above max length", ErrInvalidConstraint)
+	}
+
+	hasRange := constraint.Min != nil || constraint.Max != nil
+	if hasRange && schema.Type != TypeInt && schema.Type != TypeFloat && schema.Type != TypeDecimal {
+		return fmt.Errorf("%w: range on %s", ErrInvalidConstraint, schema.Type)
+	}
+	if hasRange && constraint.Min != nil && constraint.Max != nil && *constraint.Min > *constraint.Max {
+		return fmt.Errorf("%w: min above max", ErrInvalidConstraint)
+	}
+
+	if constraint.Pattern != "" {
+		if schema.Type != TypeString {
+			return fmt.Errorf("%w: pattern on %s", ErrInvalidConstraint, schema.Type)
+		}
+		if _, err := compilePattern(constraint.Pattern); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidConstraint, err)
+		}
+	}
+	return nil
+}
+
+// check returns a message for every rule value breaks. value is already
+// known to match the attribute type.
+func (constraint AttributeConstraint) check(value interface{}) (messages []string) {
+	length := -1
+	switch typed := value.(type) {
+	case string:
+		length = utf8.RuneCountInString(typed)
+	case []interface{}:
+		length = len(typed)
+	}
+	if length >= 0 && constraint.MinLength != nil && length < *constraint.MinLength {
+		messages = append(messages, fmt.Sprintf("length %d is below minimum %d", length, *constraint.MinLength))
+	}
+	if length >= 0 && constraint.MaxLength != nil && length > *constraint.MaxLength {
+		messages = append(messages, fmt.Sprintf("length %d is above maximum %d", length, *constraint.MaxLength))
+	}
+
+	var number float64
+	var isNumber bool
+	switch typed := value.(type) {
+	case json.Number:
+		number, isNumber = parseFloat(typed.String())
+	case string:
+		if decimalPattern.MatchString(typed) {
+			number, isNumber = parseFloat(typed)
+		}
+	}
+	if isNumber && constraint.Min != nil && number < *constraint.Min {
+		messages = append(messages, fmt.Sprintf("%v is below minimum %v", number, *constraint.Min))
+	}
+	if isNumber && constraint.Max != nil && number > *constraint.Max {
+		messages = append(messages, fmt.Sprintf("%v is above maximum %v", number, *constraint.Max))
+	}
+
+	if text, ok := value.(string); ok && constraint.Pattern != "" {
+		pattern, err := compilePattern(constraint.Pattern)
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("invalid pattern %s", constraint.Pattern))
+		} else if !pattern.MatchString(text) {
+			messages = append(messages, fmt.Sprintf("does not match pattern %s", constraint.Pattern))
+		}
+	}
+	return
+}
+
+// patterns caches compiled constraint patterns, so a pattern is compiled once
+// rather than for every value checked against it.
+var patterns sync.Map
+
+// compilePattern compiles pattern once. Patterns come from replayed events,
+// so a broken one is reported as an error rather than a panic.
+func compilePattern(pattern string) (compiled *regexp.Regexp, err error) {
+	if cached, ok := patterns.Load(pattern); ok {
+		compiled = cached.(*regexp.Regexp)
+		return
+	}
+	compiled, err = regexp.Compile(pattern)
+	if err != nil {
+		return
+	}
+	patterns.Store(pattern, compiled)
+	return
+}
+
+func parseFloat(text string) (number float64, ok bool) {
+	number, err := strconv.ParseFloat(text, 64)
+	return number, err == nil
+}
+
+func (entity *EsuiEntity) AttributeConstraintSet(event EstoreEvent) {
+	var constraintSet EsuiAttributeConstraintSet
+	err := json.Unmarshal([]byte(event.Data), &constraintSet)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	entityEvent, ok := entity.Events[constraintSet.EventName]
+	if !ok {
+		logger.Println("event not found")
+		return
+	}
+	if entityEvent.Constraints == nil {
+		entityEvent.Constraints = make(map[AttributeName]AttributeConstraint)
+	}
+	entityEvent.Constraints[constraintSet.Name] = constraintSet.Constraint
+	entity.Events[constraintSet.EventName] = entityEvent
+}
+
+// SetAttributeConstraint replaces the constraint of an attribute.
+func (es *Esui) SetAttributeConstraint(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, constraint AttributeConstraint) (err error) {
+	return es.retry(ctx, func() error {
+		return es.setAttributeConstraint(ctx, entityID, eventName, attributeName, constraint)
+	})
+}
+
+func (es *Esui) setAttributeConstraint(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, constraint AttributeConstraint) (err error) {
+	entity, err := es.loadEntity(ctx, entityID)
+	if err != nil {
+		return
+	}
+
+	event, ok := entity.Events[eventName]
+	if !ok {
+		err = ErrEventNotFound
+		logger.Println(ctx, err)
+		return
+	}
+
+	schema, ok := event.Schemas[attributeName]
+	if !ok {
+		err = ErrAttributeNotFound
+		logger.Println(ctx, err)
+		return
+	}
+
+	err = constraint.validate(schema)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	err = es.eventstore.StoreEvent(ctx, string(entityID), "entity", "attribute_constraint_set", EsuiAttributeConstraintSet{
+		EventName:  eventName,
+		Name:       attributeName,
+		Constraint: constraint,
+	}, entity.Version)
+
+	return
+}
+
+// ValidatePayload checks a JSON encoded event payload against the designed
+// schema and constraints of the event and returns every violation found. err
+// is only set when the schema itself cannot be loaded or payload is not a
+// JSON object.
+func (es *Esui) ValidatePayload(ctx context.Context, entityID ShortID, eventName string, payload json.RawMessage) (violations []Violation, err error) {
+	entity, err := es.GetEntity(ctx, entityID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	if entity.Name == "" {
+		err = ErrEntityNotFound
+		logger.Println(ctx, err)
+		return
+	}
+
+	event, ok := entity.Events[eventName]
+	if !ok {
+		err = ErrEventNotFound
+		logger.Println(ctx, err)
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	err = decoder.Decode(&values)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	violations = []Violation{}
+	for name := range values {
+		if _, ok := event.Schemas[AttributeName(name)]; !ok {
+			violations = append(violations, Violation{Attribute: AttributeName(name), Message: "unknown attribute"})
+		}
+	}
+
+	for name, schema := range event.Schemas {
+		constraint := event.Constraints[name]
+		value, ok := values[string(name)]
+		if !ok {
+			if (schema.Required || constraint.Required) && len(schema.Default) == 0 {
+				violations = append(violations, Violation{Attribute: name, Message: "required"})
+			}
+			continue
+		}
+
+		if typeErr := schema.checkValue(value); typeErr != nil {
+			violations = append(violations, Violation{Attribute: name, Message: typeErr.Error()})
+			continue
+		}
+		for _, message := range constraint.check(value) {
+			violations = append(violations, Violation{Attribute: name, Message: message})
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Attribute < violations[j].Attribute
+	})
+	return
+}
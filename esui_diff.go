@@ -0,0 +1,793 @@
+package esui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded       ChangeKind = "added"
+	ChangeRemoved     ChangeKind = "removed"
+	ChangeRenamed     ChangeKind = "renamed"
+	ChangeTypeChanged ChangeKind = "type_changed"
+	ChangeModified    ChangeKind = "modified"
+)
+
+// SchemaChange is one difference between two schemas. Object is one of
+// entity, event, attribute, projection, table, column, index, subscription or
+// block. Path joins the names leading to the object with dots, using the new
+// names except for removed objects, and [] for the items of an array. From
+// and To hold the old and new name of a rename or the old and new type of a
+// type change. Detail tells what a modification changed, such as "values
+// narrowed" or "max_length tightened".
+type SchemaChange struct {
+	Kind     ChangeKind `json:"kind"`
+	Object   string     `json:"object"`
+	Path     string     `json:"path"`
+	From     string     `json:"from,omitempty"`
+	To       string     `json:"to,omitempty"`
+	Detail   string     `json:"detail,omitempty"`
+	Breaking bool       `json:"breaking"`
+}
+
+type SchemaDiff struct {
+	Changes []SchemaChange `json:"changes"`
+}
+
+// Breaking tells whether any change breaks the producers or consumers of
+// events or the readers of projection tables.
+func (diff SchemaDiff) Breaking() bool {
+	for _, change := range diff.Changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func (diff *SchemaDiff) add(kind ChangeKind, object string, path string, from string, to string, breaking bool) {
+	diff.Changes = append(diff.Changes, SchemaChange{
+		Kind:     kind,
+		Object:   object,
+		Path:     path,
+		From:     from,
+		To:       to,
+		Breaking: breaking,
+	})
+}
+
+func (diff *SchemaDiff) modify(object string, path string, detail string, breaking bool) {
+	diff.Changes = append(diff.Changes, SchemaChange{
+		Kind:     ChangeModified,
+		Object:   object,
+		Path:     path,
+		Detail:   detail,
+		Breaking: breaking,
+	})
+}
+
+func names[K ~string, V any](source map[K]V) (keys []string) {
+	for key := range source {
+		keys = append(keys, string(key))
+	}
+	return
+}
+
+// clone deep copies a replayed definition, the handlers change maps and
+// slices in place.
+func clone[T any](value T) (copied T) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	err = json.Unmarshal(data, &copied)
+	if err != nil {
+		logger.Println(err)
+	}
+	return
+}
+
+// matchNames pairs every new name with the old name it had. hints maps new
+// names to old ones, a name without a hint is paired with the same old name
+// when no hint claimed it.
+func matchNames(oldNames []string, newNames []string, hints map[string]string) (pairs [][2]string, added []string, removed []string) {
+	old := make(map[string]bool)
+	for _, name := range oldNames {
+		old[name] = true
+	}
+	matched := make(map[string]bool)
+	sort.Strings(newNames)
+	paired := make(map[string]string)
+	for _, name := range newNames {
+		if oldName, ok := hints[name]; ok && old[oldName] && !matched[oldName] {
+			paired[name] = oldName
+			matched[oldName] = true
+		}
+	}
+	for _, name := range newNames {
+		if _, ok := paired[name]; ok {
+			continue
+		}
+		if _, hinted := hints[name]; !hinted && old[name] && !matched[name] {
+			paired[name] = name
+			matched[name] = true
+		}
+	}
+
+	for _, name := range newNames {
+		if oldName, ok := paired[name]; ok {
+			pairs = append(pairs, [2]string{oldName, name})
+		} else {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(oldNames)
+	for _, name := range oldNames {
+		if !matched[name] {
+			removed = append(removed, name)
+		}
+	}
+	return
+}
+
+// entityHints carries the renames known from an entity's history. Events
+// maps new event names to old ones, Attributes does the same per new event
+// name.
+type entityHints struct {
+	Events     map[string]string
+	Attributes map[string]map[string]string
+}
+
+func (diff *SchemaDiff) entity(oldEntity EsuiEntity, newEntity EsuiEntity, hints entityHints) {
+	pairs, added, removed := matchNames(names(oldEntity.Events), names(newEntity.Events), hints.Events)
+	for _, pair := range pairs {
+		path := newEntity.Name + "." + pair[1]
+		if pair[0] != pair[1] {
+			diff.add(ChangeRenamed, "event", path, pair[0], pair[1], true)
+		}
+		diff.attributes(path, oldEntity.Events[pair[0]], newEntity.Events[pair[1]], hints.Attributes[pair[1]])
+	}
+	for _, name := range added {
+		diff.add(ChangeAdded, "event", newEntity.Name+"."+name, "", "", false)
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "event", oldEntity.Name+"."+name, "", "", true)
+	}
+}
+
+// effective folds the Required of the constraint into the schema, both make
+// payloads carry the attribute.
+func effective(schema AttributeSchema, constraint AttributeConstraint) (AttributeSchema, AttributeConstraint) {
+	schema.Required = schema.Required || constraint.Required
+	constraint.Required = false
+	return schema, constraint
+}
+
+func (diff *SchemaDiff) attributes(path string, oldEvent EsuiEntityEvent, newEvent EsuiEntityEvent, hints map[string]string) {
+	pairs, added, removed := matchNames(names(oldEvent.Schemas), names(newEvent.Schemas), hints)
+	for _, pair := range pairs {
+		attributePath := path + "." + pair[1]
+		if pair[0] != pair[1] {
+			diff.add(ChangeRenamed, "attribute", attributePath, pair[0], pair[1], true)
+		}
+		oldSchema, oldConstraint := effective(oldEvent.Schemas[AttributeName(pair[0])], oldEvent.Constraints[AttributeName(pair[0])])
+		newSchema, newConstraint := effective(newEvent.Schemas[AttributeName(pair[1])], newEvent.Constraints[AttributeName(pair[1])])
+		if oldSchema.Type != newSchema.Type {
+			diff.add(ChangeTypeChanged, "attribute", attributePath, string(oldSchema.Type), string(newSchema.Type), true)
+			continue
+		}
+		diff.schema(attributePath, oldSchema, newSchema)
+		diff.constraint(attributePath, oldConstraint, newConstraint)
+	}
+	for _, name := range added {
+		schema, _ := effective(newEvent.Schemas[AttributeName(name)], newEvent.Constraints[AttributeName(name)])
+		diff.add(ChangeAdded, "attribute", path+"."+name, "", "", mustProvide(schema))
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "attribute", path+"."+name, "", "", true)
+	}
+}
+
+// schema compares two schemas of the same type. A change is breaking when a
+// payload that was valid before can be rejected now.
+func (diff *SchemaDiff) schema(path string, oldSchema AttributeSchema, newSchema AttributeSchema) {
+	var narrowed, widened bool
+	for _, value := range oldSchema.Values {
+		if !slices.Contains(newSchema.Values, value) {
+			narrowed = true
+		}
+	}
+	for _, value := range newSchema.Values {
+		if !slices.Contains(oldSchema.Values, value) {
+			widened = true
+		}
+	}
+	if narrowed {
+		diff.modify("attribute", path, "values narrowed", true)
+	} else if widened {
+		diff.modify("attribute", path, "values widened", false)
+	}
+
+	switch {
+	case !oldSchema.Required && newSchema.Required:
+		diff.modify("attribute", path, "required", mustProvide(newSchema))
+	case oldSchema.Required && !newSchema.Required:
+		diff.modify("attribute", path, "optional", false)
+	case !bytes.Equal(oldSchema.Default, newSchema.Default):
+		diff.modify("attribute", path, "default changed", mustProvide(newSchema) && len(oldSchema.Default) > 0)
+	}
+	if oldSchema.Nullable != newSchema.Nullable {
+		if newSchema.Nullable {
+			diff.modify("attribute", path, "nullable", false)
+		} else {
+			diff.modify("attribute", path, "not nullable", true)
+		}
+	}
+
+	switch {
+	case oldSchema.Items != nil && newSchema.Items != nil:
+		if oldSchema.Items.Type != newSchema.Items.Type {
+			diff.add(ChangeTypeChanged, "attribute", path+"[]", string(oldSchema.Items.Type), string(newSchema.Items.Type), true)
+		} else {
+			diff.schema(path+"[]", *oldSchema.Items, *newSchema.Items)
+		}
+	case oldSchema.Items != nil || newSchema.Items != nil:
+		diff.modify("attribute", path, "items changed", true)
+	}
+
+	pairs, added, removed := matchNames(names(oldSchema.Fields), names(newSchema.Fields), nil)
+	for _, pair := range pairs {
+		oldField := oldSchema.Fields[AttributeName(pair[0])]
+		newField := newSchema.Fields[AttributeName(pair[1])]
+		if oldField.Type != newField.Type {
+			diff.add(ChangeTypeChanged, "attribute", path+"."+pair[1], string(oldField.Type), string(newField.Type), true)
+			continue
+		}
+		diff.schema(path+"."+pair[1], oldField, newField)
+	}
+	for _, name := range added {
+		diff.add(ChangeAdded, "attribute", path+"."+name, "", "", mustProvide(newSchema.Fields[AttributeName(name)]))
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "attribute", path+"."+name, "", "", true)
+	}
+}
+
+// bound compares an optional limit. lower tells a minimum, which tightens
+// when it grows, from a maximum, which tightens when it shrinks.
+func bound[T int | float64](oldLimit *T, newLimit *T, lower bool) (changed bool, tighter bool) {
+	switch {
+	case oldLimit == nil && newLimit == nil:
+		return false, false
+	case newLimit == nil:
+		return true, false
+	case oldLimit == nil:
+		return true, true
+	case *oldLimit == *newLimit:
+		return false, false
+	case lower:
+		return true, *newLimit > *oldLimit
+	default:
+		return true, *newLimit < *oldLimit
+	}
+}
+
+// constraint compares two constraints, a tighter one is breaking.
+func (diff *SchemaDiff) constraint(path string, oldConstraint AttributeConstraint, newConstraint AttributeConstraint) {
+	report := func(name string, changed bool, tighter bool) {
+		if !changed {
+			return
+		}
+		if tighter {
+			diff.modify("attribute", path, name+" tightened", true)
+		} else {
+			diff.modify("attribute", path, name+" loosened", false)
+		}
+	}
+	changed, tighter := bound(oldConstraint.MinLength, newConstraint.MinLength, true)
+	report("min_length", changed, tighter)
+	changed, tighter = bound(oldConstraint.MaxLength, newConstraint.MaxLength, false)
+	report("max_length", changed, tighter)
+	changed, tighter = bound(oldConstraint.Min, newConstraint.Min, true)
+	report("min", changed, tighter)
+	changed, tighter = bound(oldConstraint.Max, newConstraint.Max, false)
+	report("max", changed, tighter)
+	// Two patterns cannot be compared, any new one may reject old values.
+	report("pattern", oldConstraint.Pattern != newConstraint.Pattern, newConstraint.Pattern != "")
+}
+
+// projectionHints carries the renames known from a projection's history.
+// Tables maps new table names to old ones, Columns does the same per new
+// table name.
+type projectionHints struct {
+	Tables  map[string]string
+	Columns map[string]map[string]string
+}
+
+func (diff *SchemaDiff) projection(oldProjection EsuiProjection, newProjection EsuiProjection, hints projectionHints) {
+	if oldProjection.IsActive && !newProjection.IsActive {
+		diff.modify("projection", newProjection.Name, "deactivated", true)
+	} else if !oldProjection.IsActive && newProjection.IsActive {
+		diff.modify("projection", newProjection.Name, "activated", false)
+	}
+
+	pairs, added, removed := matchNames(names(oldProjection.Tables), names(newProjection.Tables), hints.Tables)
+	for _, pair := range pairs {
+		path := newProjection.Name + "." + pair[1]
+		if pair[0] != pair[1] {
+			diff.add(ChangeRenamed, "table", path, pair[0], pair[1], true)
+		}
+		diff.table(path, oldProjection.Tables[pair[0]], newProjection.Tables[pair[1]], hints.Columns[pair[1]])
+	}
+	for _, name := range added {
+		diff.add(ChangeAdded, "table", newProjection.Name+"."+name, "", "", false)
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "table", oldProjection.Name+"."+name, "", "", true)
+	}
+
+	oldBlocks := make(map[string]Block)
+	for _, block := range oldProjection.Blocks {
+		oldBlocks[block.BlockID] = block
+	}
+	newBlocks := make(map[string]Block)
+	for _, block := range newProjection.Blocks {
+		newBlocks[block.BlockID] = block
+	}
+	pairs, added, removed = matchNames(names(oldBlocks), names(newBlocks), nil)
+	for _, pair := range pairs {
+		path := newProjection.Name + "." + pair[1]
+		oldBlock, newBlock := oldBlocks[pair[0]], newBlocks[pair[1]]
+		if oldBlock.Name != newBlock.Name {
+			diff.add(ChangeRenamed, "block", path, oldBlock.Name, newBlock.Name, false)
+			oldBlock.Name = newBlock.Name
+		}
+		if !reflect.DeepEqual(oldBlock, newBlock) {
+			diff.add(ChangeModified, "block", path, "", "", false)
+		}
+	}
+	for _, name := range added {
+		diff.add(ChangeAdded, "block", newProjection.Name+"."+name, "", "", false)
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "block", oldProjection.Name+"."+name, "", "", false)
+	}
+
+	subscriptions := func(projection EsuiProjection) map[string]bool {
+		keys := make(map[string]bool)
+		for entityID, eventNames := range projection.Subscriptions {
+			for eventName := range eventNames {
+				keys[string(entityID)+"."+eventName] = true
+			}
+		}
+		return keys
+	}
+	pairs, added, removed = matchNames(names(subscriptions(oldProjection)), names(subscriptions(newProjection)), nil)
+	for _, name := range added {
+		diff.add(ChangeAdded, "subscription", newProjection.Name+"."+name, "", "", false)
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "subscription", oldProjection.Name+"."+name, "", "", true)
+	}
+}
+
+func (diff *SchemaDiff) table(path string, oldTable EsuiTable, newTable EsuiTable, hints map[string]string) {
+	pairs, added, removed := matchNames(names(oldTable.Columns), names(newTable.Columns), hints)
+	renamed := make(map[string]string)
+	for _, pair := range pairs {
+		renamed[pair[0]] = pair[1]
+		columnPath := path + "." + pair[1]
+		if pair[0] != pair[1] {
+			diff.add(ChangeRenamed, "column", columnPath, pair[0], pair[1], true)
+		}
+		oldType := oldTable.Columns[pair[0]].Type
+		newType := newTable.Columns[pair[1]].Type
+		if oldType != newType {
+			diff.add(ChangeTypeChanged, "column", columnPath, string(oldType), string(newType), true)
+		}
+	}
+	for _, name := range added {
+		diff.add(ChangeAdded, "column", path+"."+name, "", "", false)
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "column", path+"."+name, "", "", true)
+	}
+
+	// Key columns are compared under their new names, so renaming a key
+	// column is not a key change.
+	current := func(columns []string) (names []string) {
+		for _, column := range columns {
+			if name, ok := renamed[column]; ok {
+				column = name
+			}
+			names = append(names, column)
+		}
+		return
+	}
+	if !slices.Equal(current(oldTable.PrimaryKey), newTable.PrimaryKey) {
+		diff.modify("table", path, "primary key changed", true)
+	}
+
+	pairs, added, removed = matchNames(names(oldTable.Indexes), names(newTable.Indexes), nil)
+	for _, pair := range pairs {
+		oldIndex, newIndex := oldTable.Indexes[pair[0]], newTable.Indexes[pair[1]]
+		sameColumns := slices.Equal(current(oldIndex.Columns), newIndex.Columns)
+		if !sameColumns || oldIndex.Unique != newIndex.Unique {
+			diff.add(ChangeModified, "index", path+"."+pair[1], "", "", newIndex.Unique && (!oldIndex.Unique || !sameColumns))
+		}
+	}
+	for _, name := range added {
+		diff.add(ChangeAdded, "index", path+"."+name, "", "", newTable.Indexes[name].Unique)
+	}
+	for _, name := range removed {
+		diff.add(ChangeRemoved, "index", path+"."+name, "", "", false)
+	}
+}
+
+// definitions turns the resolved view back into definitions, for releases
+// published before the full definitions were recorded with them.
+func (application Application) definitions() (entities map[ShortID]EsuiEntity, projections map[ShortID]EsuiProjection) {
+	entities = make(map[ShortID]EsuiEntity)
+	for entityID, entity := range application.Entity {
+		definition := EsuiEntity{
+			ID:     ShortID(entityID),
+			Name:   entity.Name,
+			Events: make(map[string]EsuiEntityEvent),
+		}
+		for eventName, event := range entity.Events {
+			schemas := make(map[AttributeName]AttributeSchema)
+			for attributeName, attributeType := range event.Attribute {
+				schemas[attributeName] = AttributeSchema{Type: attributeType}
+			}
+			definition.Events[string(eventName)] = EsuiEntityEvent{Schemas: schemas}
+		}
+		entities[ShortID(entityID)] = definition
+	}
+
+	projections = make(map[ShortID]EsuiProjection)
+	for projectionID, projection := range application.Projections {
+		definition := EsuiProjection{
+			ID:            projection.ID,
+			Name:          projection.Name,
+			Tables:        make(map[string]EsuiTable),
+			Blocks:        projection.Blocks,
+			Subscriptions: make(map[ShortID]map[string]bool),
+		}
+		for _, table := range projection.Tables {
+			columns := make(map[string]EsuiColumn)
+			for columnName, columnType := range table.Columns {
+				columns[string(columnName)] = EsuiColumn{Name: string(columnName), Type: ColumnType(columnType)}
+			}
+			definition.Tables[table.Name] = EsuiTable{Name: table.Name, ProjectionID: projection.ID, Columns: columns}
+		}
+		for entityID, eventNames := range projection.SubscribeTo {
+			events := make(map[string]bool)
+			for eventName := range eventNames {
+				events[string(eventName)] = true
+			}
+			definition.Subscriptions[ShortID(entityID)] = events
+		}
+		projections[ShortID(projectionID)] = definition
+	}
+	return
+}
+
+// DiffApplications compares two application snapshots, typically two
+// published versions. Entities and projections are matched by ID so their
+// renames are found. Events, attributes, tables and columns are matched by
+// name, a rename of those shows as a removal and an addition; DiffEntity and
+// DiffProjection follow the history and report them as renames. The views
+// only carry types, use DiffApplicationVersions to compare full schemas.
+func DiffApplications(oldApplication Application, newApplication Application) (diff SchemaDiff) {
+	oldEntities, oldProjections := oldApplication.definitions()
+	newEntities, newProjections := newApplication.definitions()
+	return diffDefinitions(oldEntities, newEntities, oldProjections, newProjections)
+}
+
+func diffDefinitions(oldEntities map[ShortID]EsuiEntity, newEntities map[ShortID]EsuiEntity, oldProjections map[ShortID]EsuiProjection, newProjections map[ShortID]EsuiProjection) (diff SchemaDiff) {
+	diff.Changes = []SchemaChange{}
+
+	var entityIDs []string
+	for id := range oldEntities {
+		entityIDs = append(entityIDs, string(id))
+	}
+	for id := range newEntities {
+		if _, ok := oldEntities[id]; !ok {
+			entityIDs = append(entityIDs, string(id))
+		}
+	}
+	sort.Strings(entityIDs)
+	for _, id := range entityIDs {
+		oldEntity, inOld := oldEntities[ShortID(id)]
+		newEntity, inNew := newEntities[ShortID(id)]
+		switch {
+		case !inOld:
+			diff.add(ChangeAdded, "entity", newEntity.Name, "", "", false)
+		case !inNew:
+			diff.add(ChangeRemoved, "entity", oldEntity.Name, "", "", true)
+		default:
+			if oldEntity.Name != newEntity.Name {
+				diff.add(ChangeRenamed, "entity", newEntity.Name, oldEntity.Name, newEntity.Name, true)
+			}
+			diff.entity(oldEntity, newEntity, entityHints{})
+		}
+	}
+
+	var projectionIDs []string
+	for id := range oldProjections {
+		projectionIDs = append(projectionIDs, string(id))
+	}
+	for id := range newProjections {
+		if _, ok := oldProjections[id]; !ok {
+			projectionIDs = append(projectionIDs, string(id))
+		}
+	}
+	sort.Strings(projectionIDs)
+	for _, id := range projectionIDs {
+		oldProjection, inOld := oldProjections[ShortID(id)]
+		newProjection, inNew := newProjections[ShortID(id)]
+		switch {
+		case !inOld:
+			diff.add(ChangeAdded, "projection", newProjection.Name, "", "", false)
+		case !inNew:
+			diff.add(ChangeRemoved, "projection", oldProjection.Name, "", "", true)
+		default:
+			if oldProjection.Name != newProjection.Name {
+				diff.add(ChangeRenamed, "projection", newProjection.Name, oldProjection.Name, newProjection.Name, true)
+			}
+			diff.projection(oldProjection, newProjection, projectionHints{})
+		}
+	}
+	return
+}
+
+// DiffApplicationVersions compares two published versions of an
+// application on their full definitions, so enum, required, nullable and
+// constraint changes are classified too. When either release predates the
+// full definitions both are compared on their resolved views.
+func (es *Esui) DiffApplicationVersions(ctx context.Context, oldVersionID ShortID, newVersionID ShortID) (diff SchemaDiff, err error) {
+	oldRelease, err := es.GetRelease(ctx, oldVersionID)
+	if err != nil {
+		return
+	}
+	newRelease, err := es.GetRelease(ctx, newVersionID)
+	if err != nil {
+		return
+	}
+	full := func(release EsuiApplicationPublished) bool {
+		return release.Entities != nil || release.Projections != nil ||
+			(len(release.Application.Entity) == 0 && len(release.Application.Projections) == 0)
+	}
+	if !full(oldRelease) || !full(newRelease) {
+		diff = DiffApplications(oldRelease.Application, newRelease.Application)
+		return
+	}
+	diff = diffDefinitions(oldRelease.Entities, newRelease.Entities, oldRelease.Projections, newRelease.Projections)
+	return
+}
+
+// span clamps fromVersion and toVersion to the count events of an aggregate,
+// AnyVersion meaning all of them.
+func span(count int, fromVersion int64, toVersion int64) (int64, int64) {
+	if toVersion == AnyVersion || toVersion > int64(count) {
+		toVersion = int64(count)
+	}
+	if fromVersion < 0 {
+		fromVersion = 0
+	}
+	if fromVersion > toVersion {
+		fromVersion = toVersion
+	}
+	return fromVersion, toVersion
+}
+
+// DiffEntity compares the entity as it was after its first fromVersion
+// events with the entity after its first toVersion events, AnyVersion
+// meaning all of them. Unlike DiffApplications it follows the history, so
+// event and attribute renames are reported as renames.
+func (es *Esui) DiffEntity(ctx context.Context, entityID ShortID, fromVersion int64, toVersion int64) (diff SchemaDiff, err error) {
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(entityID), "entity", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if len(events) == 0 {
+		err = ErrEntityNotFound
+		logger.Println(ctx, err)
+		return
+	}
+	fromVersion, toVersion = span(len(events), fromVersion, toVersion)
+
+	var entity EsuiEntity
+	for _, event := range events[:fromVersion] {
+		entity.apply(event, entityID)
+	}
+	oldEntity := clone(entity)
+
+	eventOrigins := make(map[string]string)
+	attributeOrigins := make(map[string]map[string]string)
+	for eventName, event := range entity.Events {
+		eventOrigins[eventName] = eventName
+		attributeOrigins[eventName] = make(map[string]string)
+		for attributeName := range event.Schemas {
+			attributeOrigins[eventName][string(attributeName)] = string(attributeName)
+		}
+	}
+
+	for _, event := range events[fromVersion:toVersion] {
+		entity.apply(event, entityID)
+		trackRename(event, eventOrigins, attributeOrigins)
+	}
+
+	diff.Changes = []SchemaChange{}
+	if oldEntity.Name == "" {
+		diff.add(ChangeAdded, "entity", entity.Name, "", "", false)
+		return
+	}
+	if oldEntity.Name != entity.Name {
+		diff.add(ChangeRenamed, "entity", entity.Name, oldEntity.Name, entity.Name, true)
+	}
+
+	hints := entityHints{
+		Events:     eventOrigins,
+		Attributes: make(map[string]map[string]string),
+	}
+	for eventName, origin := range eventOrigins {
+		hints.Attributes[eventName] = attributeOrigins[origin]
+	}
+	diff.entity(oldEntity, entity, hints)
+	return
+}
+
+// DiffProjection compares the projection as it was after its first
+// fromVersion events with the projection after its first toVersion events,
+// AnyVersion meaning all of them. It follows the history, so table and
+// column renames are reported as renames.
+func (es *Esui) DiffProjection(ctx context.Context, projectionID ShortID, fromVersion int64, toVersion int64) (diff SchemaDiff, err error) {
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(projectionID), "projection", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if len(events) == 0 {
+		err = ErrProjectionNotFound
+		logger.Println(ctx, err)
+		return
+	}
+	fromVersion, toVersion = span(len(events), fromVersion, toVersion)
+
+	var projection EsuiProjection
+	for _, event := range events[:fromVersion] {
+		projection.apply(event, projectionID)
+	}
+	oldProjection := clone(projection)
+
+	tableOrigins := make(map[string]string)
+	columnOrigins := make(map[string]map[string]string)
+	for tableName, table := range projection.Tables {
+		tableOrigins[tableName] = tableName
+		columnOrigins[tableName] = make(map[string]string)
+		for columnName := range table.Columns {
+			columnOrigins[tableName][columnName] = columnName
+		}
+	}
+
+	for _, event := range events[fromVersion:toVersion] {
+		projection.apply(event, projectionID)
+		trackTableRename(event, tableOrigins, columnOrigins)
+	}
+
+	diff.Changes = []SchemaChange{}
+	if oldProjection.Name == "" {
+		diff.add(ChangeAdded, "projection", projection.Name, "", "", false)
+		return
+	}
+	if oldProjection.Name != projection.Name {
+		diff.add(ChangeRenamed, "projection", projection.Name, oldProjection.Name, projection.Name, true)
+	}
+
+	hints := projectionHints{
+		Tables:  tableOrigins,
+		Columns: make(map[string]map[string]string),
+	}
+	for tableName, origin := range tableOrigins {
+		hints.Columns[tableName] = columnOrigins[origin]
+	}
+	diff.projection(oldProjection, projection, hints)
+	return
+}
+
+// trackRename keeps eventOrigins, current event name to original name, and
+// attributeOrigins, per original event the current attribute name to the
+// original one, up to date with one entity event. Removed names are
+// forgotten.
+func trackRename(event EstoreEvent, eventOrigins map[string]string, attributeOrigins map[string]map[string]string) {
+	switch event.EventName {
+	case "event_renamed":
+		var renamed EsuiEventRenamed
+		if err := json.Unmarshal([]byte(event.Data), &renamed); err != nil {
+			logger.Println(err)
+			return
+		}
+		if origin, ok := eventOrigins[renamed.Name]; ok {
+			delete(eventOrigins, renamed.Name)
+			eventOrigins[renamed.NewName] = origin
+		}
+	case "event_removed":
+		var removed EsuiEventRemoved
+		if err := json.Unmarshal([]byte(event.Data), &removed); err != nil {
+			logger.Println(err)
+			return
+		}
+		delete(eventOrigins, removed.Name)
+	case "attribute_renamed":
+		var renamed EsuiAttributeRenamed
+		if err := json.Unmarshal([]byte(event.Data), &renamed); err != nil {
+			logger.Println(err)
+			return
+		}
+		attributes := attributeOrigins[eventOrigins[renamed.EventName]]
+		if origin, ok := attributes[string(renamed.Name)]; ok {
+			delete(attributes, string(renamed.Name))
+			attributes[string(renamed.NewName)] = origin
+		}
+	case "attribute_removed":
+		var removed EsuiAttributeRemoved
+		if err := json.Unmarshal([]byte(event.Data), &removed); err != nil {
+			logger.Println(err)
+			return
+		}
+		delete(attributeOrigins[eventOrigins[removed.EventName]], string(removed.Name))
+	}
+}
+
+// trackTableRename is trackRename for the tables and columns of a
+// projection.
+func trackTableRename(event EstoreEvent, tableOrigins map[string]string, columnOrigins map[string]map[string]string) {
+	switch event.EventName {
+	case "table_renamed":
+		var renamed EsuiTableRenamed
+		if err := json.Unmarshal([]byte(event.Data), &renamed); err != nil {
+			logger.Println(err)
+			return
+		}
+		if origin, ok := tableOrigins[renamed.Name]; ok {
+			delete(tableOrigins, renamed.Name)
+			tableOrigins[renamed.NewName] = origin
+		}
+	case "table_dropped":
+		var dropped EsuiTableDropped
+		if err := json.Unmarshal([]byte(event.Data), &dropped); err != nil {
+			logger.Println(err)
+			return
+		}
+		delete(tableOrigins, dropped.Name)
+	case "column_renamed":
+		var renamed EsuiColumnRenamed
+		if err := json.Unmarshal([]byte(event.Data), &renamed); err != nil {
+			logger.Println(err)
+			return
+		}
+		columns := columnOrigins[tableOrigins[renamed.TableName]]
+		if origin, ok := columns[renamed.Name]; ok {
+			delete(columns, renamed.Name)
+			columns[renamed.NewName] = origin
+		}
+	case "column_dropped":
+		var dropped EsuiColumnDropped
+		if err := json.Unmarshal([]byte(event.Data), &dropped); err != nil {
+			logger.Println(err)
+			return
+		}
+		delete(columnOrigins[tableOrigins[dropped.TableName]], dropped.Name)
+	}
+}
@@ -0,0 +1,342 @@
+package esui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var (
+	ErrEmptyName  = errors.New("name is empty")
+	ErrEventInUse = errors.New("event used by a projection")
+)
+
+type EsuiEntityRenamed struct {
+	Name string `json:"name"`
+}
+
+type EsuiEventRemoved struct {
+	Name string `json:"name"`
+}
+
+type EsuiEventRenamed struct {
+	Name    string `json:"name"`
+	NewName string `json:"new_name"`
+}
+
+type EsuiAttributeRemoved struct {
+	EventName string        `json:"event_name"`
+	Name      AttributeName `json:"name"`
+}
+
+type EsuiAttributeRenamed struct {
+	EventName string        `json:"event_name"`
+	Name      AttributeName `json:"name"`
+	NewName   AttributeName `json:"new_name"`
+}
+
+type EsuiAttributeTypeChanged struct {
+	EventName string          `json:"event_name"`
+	Name      AttributeName   `json:"name"`
+	Schema    AttributeSchema `json:"schema"`
+}
+
+// checkEventNotInUse guards removing, renaming or archiving an entity event
+// that a projection still consumes, which would leave the projection
+// subscribed to a name that no longer exists. Which projections consume an
+// event is recorded by SubscribeProjection, see subscribers.
+func (es *Esui) checkEventNotInUse(ctx context.Context, entityID ShortID, eventName string) (err error) {
+	projectionIDs, err := es.subscribers(ctx, entityID, eventName)
+	if err != nil {
+		return
+	}
+	if len(projectionIDs) > 0 {
+		err = fmt.Errorf("%w: %s", ErrEventInUse, projectionIDs[0])
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// checkEntityNameFree fails with ErrEntityExists when an entity other than
+// entityID that is not archived is named name. Runners match domain events to
+// entities by their aggregate name, so two entities sharing a name would feed
+// each other's projections.
+func (es *Esui) checkEntityNameFree(ctx context.Context, entityID ShortID, name string) (err error) {
+	index := &es.index
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	err = es.catchUpIndex(ctx)
+	if err != nil {
+		return
+	}
+	items, _ := index.catalog.ListEntities(ListOptions{NameContains: name})
+	for _, item := range items {
+		if item.Name == name && item.ID != entityID {
+			err = fmt.Errorf("%w: %s", ErrEntityExists, name)
+			logger.Println(ctx, err)
+			return
+		}
+	}
+	return
+}
+
+// RenameEntity refuses a name another entity has with ErrEntityExists, and
+// refuses with ErrEventInUse while a projection subscribes to one of the
+// entity's events, as runners find those events by the entity name.
+func (es *Esui) RenameEntity(ctx context.Context, entityID ShortID, name string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		if name == "" {
+			return ErrEmptyName
+		}
+		entity, err := es.loadEntity(ctx, entityID)
+		if err != nil {
+			return
+		}
+		for eventName := range entity.Events {
+			err = es.checkEventNotInUse(ctx, entityID, eventName)
+			if err != nil {
+				return
+			}
+		}
+		err = es.checkEntityNameFree(ctx, entityID, name)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "renamed", EsuiEntityRenamed{
+			Name: name,
+		}, entity.Version)
+	})
+}
+
+// RemoveEventFromEntity refuses to remove an event that a projection
+// subscribes to with ErrEventInUse.
+func (es *Esui) RemoveEventFromEntity(ctx context.Context, entityID ShortID, eventName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		entity, err := es.loadEntity(ctx, entityID)
+		if err != nil {
+			return
+		}
+		if _, ok := entity.Events[eventName]; !ok {
+			err = ErrEventNotFound
+			logger.Println(ctx, err)
+			return
+		}
+		err = es.checkEventNotInUse(ctx, entityID, eventName)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "event_removed", EsuiEventRemoved{
+			Name: eventName,
+		}, entity.Version)
+	})
+}
+
+// RenameEvent refuses to rename an event that a projection subscribes to with
+// ErrEventInUse.
+func (es *Esui) RenameEvent(ctx context.Context, entityID ShortID, eventName string, newName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		if newName == "" {
+			return ErrEmptyName
+		}
+		entity, err := es.loadEntity(ctx, entityID)
+		if err != nil {
+			return
+		}
+		if _, ok := entity.Events[eventName]; !ok {
+			err = ErrEventNotFound
+			logger.Println(ctx, err)
+			return
+		}
+		if _, ok := entity.Events[newName]; ok {
+			err = ErrEventExists
+			logger.Println(ctx, err)
+			return
+		}
+		err = es.checkEventNotInUse(ctx, entityID, eventName)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "event_renamed", EsuiEventRenamed{
+			Name:    eventName,
+			NewName: newName,
+		}, entity.Version)
+	})
+}
+
+// loadAttribute loads the entity and checks that the event has the attribute.
+func (es *Esui) loadAttribute(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName) (entity EsuiEntity, err error) {
+	entity, err = es.loadEntity(ctx, entityID)
+	if err != nil {
+		return
+	}
+	event, ok := entity.Events[eventName]
+	if !ok {
+		err = ErrEventNotFound
+		logger.Println(ctx, err)
+		return
+	}
+	if _, ok := event.Schemas[attributeName]; !ok {
+		err = ErrAttributeNotFound
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+func (es *Esui) RemoveAttribute(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName) (err error) {
+	return es.retry(ctx, func() (err error) {
+		entity, err := es.loadAttribute(ctx, entityID, eventName, attributeName)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "attribute_removed", EsuiAttributeRemoved{
+			EventName: eventName,
+			Name:      attributeName,
+		}, entity.Version)
+	})
+}
+
+func (es *Esui) RenameAttribute(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, newName AttributeName) (err error) {
+	return es.retry(ctx, func() (err error) {
+		if newName == "" {
+			return ErrEmptyName
+		}
+		entity, err := es.loadAttribute(ctx, entityID, eventName, attributeName)
+		if err != nil {
+			return
+		}
+		if _, ok := entity.Events[eventName].Schemas[newName]; ok {
+			err = ErrAttributeExists
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "attribute_renamed", EsuiAttributeRenamed{
+			EventName: eventName,
+			Name:      attributeName,
+			NewName:   newName,
+		}, entity.Version)
+	})
+}
+
+func (es *Esui) ChangeAttributeType(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, attributeType AttributeType) (err error) {
+	return es.ChangeAttributeSchema(ctx, entityID, eventName, attributeName, AttributeSchema{
+		Type: attributeType,
+	})
+}
+
+// ChangeAttributeSchema replaces the schema of an attribute. A constraint
+// already set on the attribute must still fit the new type.
+func (es *Esui) ChangeAttributeSchema(ctx context.Context, entityID ShortID, eventName string, attributeName AttributeName, schema AttributeSchema) (err error) {
+	return es.retry(ctx, func() (err error) {
+		err = schema.Validate()
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		entity, err := es.loadAttribute(ctx, entityID, eventName, attributeName)
+		if err != nil {
+			return
+		}
+		if constraint, ok := entity.Events[eventName].Constraints[attributeName]; ok {
+			err = constraint.validate(schema)
+			if err != nil {
+				logger.Println(ctx, err)
+				return
+			}
+		}
+		return es.eventstore.StoreEvent(ctx, string(entityID), "entity", "attribute_type_changed", EsuiAttributeTypeChanged{
+			EventName: eventName,
+			Name:      attributeName,
+			Schema:    schema,
+		}, entity.Version)
+	})
+}
+
+func (entity *EsuiEntity) Renamed(event EstoreEvent) {
+	var renamed EsuiEntityRenamed
+	err := json.Unmarshal([]byte(event.Data), &renamed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	entity.Name = renamed.Name
+}
+
+func (entity *EsuiEntity) EventRemoved(event EstoreEvent) {
+	var removed EsuiEventRemoved
+	err := json.Unmarshal([]byte(event.Data), &removed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	delete(entity.Events, removed.Name)
+}
+
+func (entity *EsuiEntity) EventRenamed(event EstoreEvent) {
+	var renamed EsuiEventRenamed
+	err := json.Unmarshal([]byte(event.Data), &renamed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	entityEvent, ok := entity.Events[renamed.Name]
+	if !ok {
+		logger.Println("event not found")
+		return
+	}
+	delete(entity.Events, renamed.Name)
+	entity.Events[renamed.NewName] = entityEvent
+}
+
+func (entity *EsuiEntity) AttributeRemoved(event EstoreEvent) {
+	var removed EsuiAttributeRemoved
+	err := json.Unmarshal([]byte(event.Data), &removed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	entityEvent := entity.Events[removed.EventName]
+	delete(entityEvent.Attributes, removed.Name)
+	delete(entityEvent.Schemas, removed.Name)
+	delete(entityEvent.Constraints, removed.Name)
+}
+
+func (entity *EsuiEntity) AttributeRenamed(event EstoreEvent) {
+	var renamed EsuiAttributeRenamed
+	err := json.Unmarshal([]byte(event.Data), &renamed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	entityEvent := entity.Events[renamed.EventName]
+	if attributeType, ok := entityEvent.Attributes[renamed.Name]; ok {
+		delete(entityEvent.Attributes, renamed.Name)
+		entityEvent.Attributes[renamed.NewName] = attributeType
+	}
+	if schema, ok := entityEvent.Schemas[renamed.Name]; ok {
+		delete(entityEvent.Schemas, renamed.Name)
+		entityEvent.Schemas[renamed.NewName] = schema
+	}
+	if constraint, ok := entityEvent.Constraints[renamed.Name]; ok {
+		delete(entityEvent.Constraints, renamed.Name)
+		entityEvent.Constraints[renamed.NewName] = constraint
+	}
+}
+
+func (entity *EsuiEntity) AttributeTypeChanged(event EstoreEvent) {
+	var changed EsuiAttributeTypeChanged
+	err := json.Unmarshal([]byte(event.Data), &changed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	entityEvent := entity.Events[changed.EventName]
+	if _, ok := entityEvent.Schemas[changed.Name]; !ok {
+		logger.Println("attribute not found")
+		return
+	}
+	entityEvent.Attributes[changed.Name] = changed.Schema.Type
+	entityEvent.Schemas[changed.Name] = changed.Schema
+}
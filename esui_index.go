@@ -0,0 +1,57 @@
+package esui
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+// schemaIndex follows the entity and projection events of the global stream.
+// Each lookup reads only what was stored since the previous one instead of
+// replaying every aggregate again.
+type schemaIndex struct {
+	mu          sync.Mutex
+	position    int64
+	projections map[ShortID]*EsuiProjection
+	catalog     *Catalog
+}
+
+const schemaIndexBatch = 500
+
+// catchUpIndex applies the events stored since the previous lookup. The
+// caller holds es.index.mu.
+func (es *Esui) catchUpIndex(ctx context.Context) (err error) {
+	index := &es.index
+	if index.projections == nil {
+		index.projections = make(map[ShortID]*EsuiProjection)
+		index.catalog = NewCatalog()
+	}
+	for {
+		var events []EstoreEvent
+		events, err = es.eventstore.FetchAllEvents(ctx, index.position, schemaIndexBatch)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		for _, event := range events {
+			index.position = event.Position
+			err = index.catalog.Handle(ctx, event)
+			if err != nil {
+				return
+			}
+			if event.AggregateName != "projection" {
+				continue
+			}
+			projection, ok := index.projections[event.AggregateID]
+			if !ok {
+				projection = &EsuiProjection{}
+				index.projections[event.AggregateID] = projection
+			}
+			projection.apply(event, event.AggregateID)
+		}
+		if len(events) < schemaIndexBatch {
+			return
+		}
+	}
+}
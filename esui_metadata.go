@@ -0,0 +1,58 @@
+package esui
+
+import "context"
+
+// EventMetadata tells who caused an event and why. Stores take it from the
+// context passed to StoreEvent, so every Esui method records the metadata of
+// the context it is called with.
+type EventMetadata struct {
+	ActorID string `json:"actor_id,omitempty"`
+	// CorrelationID groups every event that follows from one request.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// CausationID is the ID of the event or command that caused this one.
+	CausationID string `json:"causation_id,omitempty"`
+}
+
+type metadataKey struct{}
+
+// MetadataFromContext returns the metadata set on ctx, empty when none is.
+func MetadataFromContext(ctx context.Context) EventMetadata {
+	metadata, _ := ctx.Value(metadataKey{}).(EventMetadata)
+	return metadata
+}
+
+// WithMetadata replaces the metadata of ctx.
+func WithMetadata(ctx context.Context, metadata EventMetadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+func WithActor(ctx context.Context, actorID string) context.Context {
+	metadata := MetadataFromContext(ctx)
+	metadata.ActorID = actorID
+	return WithMetadata(ctx, metadata)
+}
+
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	metadata := MetadataFromContext(ctx)
+	metadata.CorrelationID = correlationID
+	return WithMetadata(ctx, metadata)
+}
+
+func WithCausationID(ctx context.Context, causationID string) context.Context {
+	metadata := MetadataFromContext(ctx)
+	metadata.CausationID = causationID
+	return WithMetadata(ctx, metadata)
+}
+
+// CausedBy prepares ctx for events stored while handling event: they are
+// caused by it and share its correlation ID, or its ID when it has none.
+// The actor of ctx is kept.
+func CausedBy(ctx context.Context, event EstoreEvent) context.Context {
+	metadata := MetadataFromContext(ctx)
+	metadata.CausationID = string(event.EventID)
+	metadata.CorrelationID = event.Metadata.CorrelationID
+	if metadata.CorrelationID == "" {
+		metadata.CorrelationID = string(event.EventID)
+	}
+	return WithMetadata(ctx, metadata)
+}
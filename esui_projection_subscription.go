@@ -0,0 +1,117 @@
+package esui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var (
+	ErrAlreadySubscribed = errors.New("projection already subscribed")
+	ErrNotSubscribed     = errors.New("projection not subscribed")
+)
+
+type EsuiProjectionSubscribed struct {
+	EntityID  ShortID `json:"entity_id"`
+	EventName string  `json:"event_name"`
+}
+
+// SubscribeProjection makes the projection consume eventName of the entity.
+func (es *Esui) SubscribeProjection(ctx context.Context, projectionID ShortID, entityID ShortID, eventName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadInactiveProjection(ctx, projectionID)
+		if err != nil {
+			return
+		}
+		entity, err := es.loadEntity(ctx, entityID)
+		if err != nil {
+			return
+		}
+		if _, ok := entity.Events[eventName]; !ok {
+			err = ErrEventNotFound
+			logger.Println(ctx, err)
+			return
+		}
+		if projection.Subscriptions[entityID][eventName] {
+			err = ErrAlreadySubscribed
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "subscribed", EsuiProjectionSubscribed{
+			EntityID:  entityID,
+			EventName: eventName,
+		}, projection.Version)
+	})
+}
+
+func (es *Esui) UnsubscribeProjection(ctx context.Context, projectionID ShortID, entityID ShortID, eventName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, err := es.loadInactiveProjection(ctx, projectionID)
+		if err != nil {
+			return
+		}
+		if !projection.Subscriptions[entityID][eventName] {
+			err = ErrNotSubscribed
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "unsubscribed", EsuiProjectionSubscribed{
+			EntityID:  entityID,
+			EventName: eventName,
+		}, projection.Version)
+	})
+}
+
+func (projection *EsuiProjection) HandleSubscribed(event EstoreEvent) {
+	var subscribed EsuiProjectionSubscribed
+	err := json.Unmarshal([]byte(event.Data), &subscribed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	if projection.Subscriptions == nil {
+		projection.Subscriptions = make(map[ShortID]map[string]bool)
+	}
+	if projection.Subscriptions[subscribed.EntityID] == nil {
+		projection.Subscriptions[subscribed.EntityID] = make(map[string]bool)
+	}
+	projection.Subscriptions[subscribed.EntityID][subscribed.EventName] = true
+}
+
+func (projection *EsuiProjection) HandleUnsubscribed(event EstoreEvent) {
+	var unsubscribed EsuiProjectionSubscribed
+	err := json.Unmarshal([]byte(event.Data), &unsubscribed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	delete(projection.Subscriptions[unsubscribed.EntityID], unsubscribed.EventName)
+	if len(projection.Subscriptions[unsubscribed.EntityID]) == 0 {
+		delete(projection.Subscriptions, unsubscribed.EntityID)
+	}
+}
+
+// subscribers returns the projections, archived ones excluded, that consume
+// eventName of the entity.
+func (es *Esui) subscribers(ctx context.Context, entityID ShortID, eventName string) (projectionIDs []ShortID, err error) {
+	index := &es.index
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	err = es.catchUpIndex(ctx)
+	if err != nil {
+		return
+	}
+
+	for projectionID, projection := range index.projections {
+		if !projection.Archived && projection.Subscriptions[entityID][eventName] {
+			projectionIDs = append(projectionIDs, projectionID)
+		}
+	}
+	sort.Slice(projectionIDs, func(i, j int) bool {
+		return projectionIDs[i] < projectionIDs[j]
+	})
+	return
+}
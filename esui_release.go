@@ -0,0 +1,178 @@
+package esui
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var ErrVersionNotFound = errors.New("application version not found")
+
+// EsuiApplicationPublished is the only event of a release aggregate. The
+// aggregate ID is the version ID, so a release can never change. Application
+// is the resolved view, Entities and Projections the full definitions it was
+// resolved from with their aggregate versions cleared.
+type EsuiApplicationPublished struct {
+	Application Application                `json:"application"`
+	Entities    map[ShortID]EsuiEntity     `json:"entities,omitempty"`
+	Projections map[ShortID]EsuiProjection `json:"projections,omitempty"`
+}
+
+type EsuiVersionPublished struct {
+	VersionID ShortID `json:"version_id"`
+}
+
+// versionID addresses a release by its content, the full definitions
+// included, so narrowing an enum or adding an index is a new version. Maps
+// are encoded with sorted keys and PublishApplicationVersion puts blocks in
+// run order, so equal content gets equal IDs.
+func versionID(release EsuiApplicationPublished) (id ShortID, err error) {
+	release.Application.Version = ""
+	data, err := json.Marshal(release)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	id = ShortID(hex.EncodeToString(sum[:]))
+	return
+}
+
+// PublishApplicationVersion freezes the current definitions of the
+// application's entities and projections into a release and returns its
+// version ID. Publishing unchanged definitions again returns the same ID.
+func (es *Esui) PublishApplicationVersion(ctx context.Context, applicationID ShortID) (versionID ShortID, err error) {
+	err = es.retry(ctx, func() (err error) {
+		esuiApplication, err := es.loadApplication(ctx, applicationID)
+		if err != nil {
+			return
+		}
+		entities, projections, err := es.definitions(ctx, esuiApplication)
+		if err != nil {
+			return
+		}
+		for entityID, entity := range entities {
+			entity.Version = 0
+			entities[entityID] = entity
+		}
+		for projectionID, projection := range projections {
+			projection.Version = 0
+			// Blocks are stored in the order they were added, which
+			// removing and adding a block back changes.
+			if blocks, orderErr := projection.OrderedBlocks(); orderErr == nil {
+				projection.Blocks = blocks
+			}
+			projections[projectionID] = projection
+		}
+		versionID, err = es.publishRelease(ctx, EsuiApplicationPublished{
+			Application: resolveApplication(esuiApplication, entities, projections),
+			Entities:    entities,
+			Projections: projections,
+		})
+		if err != nil {
+			return
+		}
+		if esuiApplication.LatestVersion() == versionID {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(applicationID), "application", "version_published", EsuiVersionPublished{
+			VersionID: versionID,
+		}, esuiApplication.Version)
+	})
+	return
+}
+
+// publishRelease stores the release unless the same content was published
+// before.
+func (es *Esui) publishRelease(ctx context.Context, release EsuiApplicationPublished) (id ShortID, err error) {
+	id, err = versionID(release)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(id), "release", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if len(events) > 0 {
+		return
+	}
+
+	release.Application.Version = id
+	err = es.eventstore.StoreEvent(ctx, string(id), "release", "published", release, 0)
+	if errors.Is(err, ErrConcurrency) {
+		// Published concurrently with the same content.
+		err = nil
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// GetApplicationVersion returns the application exactly as it was
+// published under versionID.
+func (es *Esui) GetApplicationVersion(ctx context.Context, versionID ShortID) (application Application, err error) {
+	release, err := es.GetRelease(ctx, versionID)
+	if err != nil {
+		return
+	}
+	application = release.Application
+	return
+}
+
+// GetRelease returns everything published under versionID, the full entity
+// and projection definitions included. Releases published before those were
+// recorded only have the Application.
+func (es *Esui) GetRelease(ctx context.Context, versionID ShortID) (release EsuiApplicationPublished, err error) {
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(versionID), "release", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if len(events) == 0 {
+		err = ErrVersionNotFound
+		logger.Println(ctx, err)
+		return
+	}
+
+	err = json.Unmarshal([]byte(events[0].Data), &release)
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// ListApplicationVersions returns the version IDs the application
+// published, oldest first.
+func (es *Esui) ListApplicationVersions(ctx context.Context, applicationID ShortID) (versionIDs []ShortID, err error) {
+	application, err := es.loadApplication(ctx, applicationID)
+	if err != nil {
+		return
+	}
+	versionIDs = append([]ShortID{}, application.Releases...)
+	return
+}
+
+// LatestVersion is the version ID published last, empty before the first
+// release.
+func (application EsuiApplication) LatestVersion() ShortID {
+	if len(application.Releases) == 0 {
+		return ""
+	}
+	return application.Releases[len(application.Releases)-1]
+}
+
+func (application *EsuiApplication) HandleVersionPublished(event EstoreEvent) {
+	var published EsuiVersionPublished
+	err := json.Unmarshal([]byte(event.Data), &published)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	application.Releases = append(application.Releases, published.VersionID)
+}
@@ -0,0 +1,135 @@
+package esui
+
+import (
+	"context"
+	"time"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+// EventStream is implemented by eventstores that can read every aggregate in
+// one global order. Position is strictly increasing across the whole store.
+type EventStream interface {
+	FetchAllEvents(ctx context.Context, fromPosition int64, limit int) (events []EstoreEvent, err error)
+}
+
+// CheckpointStore remembers the last position a named subscription handled.
+// An unknown name loads as position 0.
+type CheckpointStore interface {
+	LoadCheckpoint(ctx context.Context, name string) (position int64, err error)
+	SaveCheckpoint(ctx context.Context, name string, position int64) (err error)
+}
+
+// changeNotifier is implemented by streams that can wake subscribers as soon
+// as an event is stored instead of waiting for the next poll.
+type changeNotifier interface {
+	Changed() <-chan struct{}
+}
+
+type EventHandler func(ctx context.Context, event EstoreEvent) error
+
+type Subscription struct {
+	name         string
+	stream       EventStream
+	checkpoints  CheckpointStore
+	handler      EventHandler
+	batchSize    int
+	pollInterval time.Duration
+}
+
+type SubscriptionOption func(*Subscription)
+
+func WithBatchSize(batchSize int) SubscriptionOption {
+	return func(s *Subscription) {
+		s.batchSize = batchSize
+	}
+}
+
+func WithPollInterval(pollInterval time.Duration) SubscriptionOption {
+	return func(s *Subscription) {
+		s.pollInterval = pollInterval
+	}
+}
+
+func NewSubscription(
+	name string,
+	stream EventStream,
+	checkpoints CheckpointStore,
+	handler EventHandler,
+	options ...SubscriptionOption,
+) (obj *Subscription) {
+	obj = &Subscription{
+		name:         name,
+		stream:       stream,
+		checkpoints:  checkpoints,
+		handler:      handler,
+		batchSize:    100,
+		pollInterval: time.Second,
+	}
+	for _, option := range options {
+		option(obj)
+	}
+	return obj
+}
+
+// CatchUp hands every event after the saved checkpoint to the handler and
+// returns once the stream is exhausted. The checkpoint is saved after each
+// handled event, so a failing handler sees the same event again next time.
+// Handlers get a context prepared with CausedBy, so events they store are
+// traced back to the event handled.
+func (s *Subscription) CatchUp(ctx context.Context) (err error) {
+	position, err := s.checkpoints.LoadCheckpoint(ctx, s.name)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	for {
+		var events []EstoreEvent
+		events, err = s.stream.FetchAllEvents(ctx, position, s.batchSize)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+
+		for _, event := range events {
+			err = s.handler(CausedBy(ctx, event), event)
+			if err != nil {
+				logger.Println(ctx, err)
+				return
+			}
+			position = event.Position
+			err = s.checkpoints.SaveCheckpoint(ctx, s.name, position)
+			if err != nil {
+				logger.Println(ctx, err)
+				return
+			}
+		}
+	}
+}
+
+// Run catches up and then keeps following the stream until ctx is done or
+// the handler fails.
+func (s *Subscription) Run(ctx context.Context) (err error) {
+	for {
+		var changed <-chan struct{}
+		if notifier, ok := s.stream.(changeNotifier); ok {
+			changed = notifier.Changed()
+		}
+
+		err = s.CatchUp(ctx)
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
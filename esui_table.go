@@ -0,0 +1,450 @@
+package esui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+var (
+	ErrInvalidColumnType = errors.New("invalid column type")
+	ErrColumnNotFound    = errors.New("column not found")
+	ErrInvalidKey        = errors.New("invalid key")
+	ErrIndexExists       = errors.New("index already exist")
+	ErrIndexNotFound     = errors.New("index not found")
+	ErrTableExists       = errors.New("table already exist")
+	ErrColumnExists      = errors.New("column already exist")
+	ErrColumnInUse       = errors.New("column used by a key")
+)
+
+type ColumnType string
+
+const (
+	ColumnString   ColumnType = "string"
+	ColumnInt      ColumnType = "int"
+	ColumnFloat    ColumnType = "float"
+	ColumnBool     ColumnType = "bool"
+	ColumnDatetime ColumnType = "datetime"
+	ColumnDecimal  ColumnType = "decimal"
+	ColumnJSON     ColumnType = "json"
+)
+
+func (ctype ColumnType) Validate() error {
+	switch ctype {
+	case ColumnString, ColumnInt, ColumnFloat, ColumnBool, ColumnDatetime, ColumnDecimal, ColumnJSON:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidColumnType, ctype)
+}
+
+// EsuiIndex is a secondary index over Columns, in that order.
+type EsuiIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+type EsuiPrimaryKeySet struct {
+	TableName string   `json:"table_name"`
+	Columns   []string `json:"columns"`
+}
+
+type EsuiIndexAdded struct {
+	TableName string    `json:"table_name"`
+	Index     EsuiIndex `json:"index"`
+}
+
+type EsuiIndexRemoved struct {
+	TableName string `json:"table_name"`
+	Name      string `json:"name"`
+}
+
+type EsuiTableDropped struct {
+	Name string `json:"name"`
+}
+
+type EsuiTableRenamed struct {
+	Name    string `json:"name"`
+	NewName string `json:"new_name"`
+}
+
+type EsuiColumnDropped struct {
+	TableName string `json:"table_name"`
+	Name      string `json:"name"`
+}
+
+type EsuiColumnRenamed struct {
+	TableName string `json:"table_name"`
+	Name      string `json:"name"`
+	NewName   string `json:"new_name"`
+}
+
+type EsuiColumnTypeChanged struct {
+	TableName string     `json:"table_name"`
+	Name      string     `json:"name"`
+	Type      ColumnType `json:"type"`
+}
+
+// checkKey validates the columns of a primary key or an index.
+func (table EsuiTable) checkKey(columns []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("%w: no column", ErrInvalidKey)
+	}
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		if _, ok := table.Columns[column]; !ok {
+			return fmt.Errorf("%w: %s", ErrColumnNotFound, column)
+		}
+		if seen[column] {
+			return fmt.Errorf("%w: duplicate column %s", ErrInvalidKey, column)
+		}
+		seen[column] = true
+	}
+	return nil
+}
+
+// loadTable is loadProjection for commands on one table.
+func (es *Esui) loadTable(ctx context.Context, projectionID ShortID, tableName string) (projection EsuiProjection, table EsuiTable, err error) {
+	projection, err = es.loadInactiveProjection(ctx, projectionID)
+	if err != nil {
+		return
+	}
+	table, ok := projection.Tables[tableName]
+	if !ok {
+		err = ErrTableNotFound
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// loadColumn is loadTable that also checks the table has the column.
+func (es *Esui) loadColumn(ctx context.Context, projectionID ShortID, tableName string, columnName string) (projection EsuiProjection, table EsuiTable, err error) {
+	projection, table, err = es.loadTable(ctx, projectionID, tableName)
+	if err != nil {
+		return
+	}
+	if _, ok := table.Columns[columnName]; !ok {
+		err = ErrColumnNotFound
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// keyUses tells whether the primary key or an index of the table covers the
+// column.
+func (table EsuiTable) keyUses(columnName string) bool {
+	for _, column := range table.PrimaryKey {
+		if column == columnName {
+			return true
+		}
+	}
+	for _, index := range table.Indexes {
+		for _, column := range index.Columns {
+			if column == columnName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (es *Esui) DropTable(ctx context.Context, projectionID ShortID, tableName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, _, err := es.loadTable(ctx, projectionID, tableName)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "table_dropped", EsuiTableDropped{
+			Name: tableName,
+		}, projection.Version)
+	})
+}
+
+func (es *Esui) RenameTable(ctx context.Context, projectionID ShortID, tableName string, newName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		if newName == "" {
+			return ErrEmptyName
+		}
+		projection, _, err := es.loadTable(ctx, projectionID, tableName)
+		if err != nil {
+			return
+		}
+		if _, ok := projection.Tables[newName]; ok {
+			err = ErrTableExists
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "table_renamed", EsuiTableRenamed{
+			Name:    tableName,
+			NewName: newName,
+		}, projection.Version)
+	})
+}
+
+// DropColumn refuses to drop a column the primary key or an index uses with
+// ErrColumnInUse.
+func (es *Esui) DropColumn(ctx context.Context, projectionID ShortID, tableName string, columnName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, table, err := es.loadColumn(ctx, projectionID, tableName, columnName)
+		if err != nil {
+			return
+		}
+		if table.keyUses(columnName) {
+			err = ErrColumnInUse
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "column_dropped", EsuiColumnDropped{
+			TableName: tableName,
+			Name:      columnName,
+		}, projection.Version)
+	})
+}
+
+// RenameColumn also renames the column in the primary key and indexes.
+func (es *Esui) RenameColumn(ctx context.Context, projectionID ShortID, tableName string, columnName string, newName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		if newName == "" {
+			return ErrEmptyName
+		}
+		projection, table, err := es.loadColumn(ctx, projectionID, tableName, columnName)
+		if err != nil {
+			return
+		}
+		if _, ok := table.Columns[newName]; ok {
+			err = ErrColumnExists
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "column_renamed", EsuiColumnRenamed{
+			TableName: tableName,
+			Name:      columnName,
+			NewName:   newName,
+		}, projection.Version)
+	})
+}
+
+func (es *Esui) ChangeColumnType(ctx context.Context, projectionID ShortID, tableName string, columnName string, columnType ColumnType) (err error) {
+	return es.retry(ctx, func() (err error) {
+		err = columnType.Validate()
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		projection, _, err := es.loadColumn(ctx, projectionID, tableName, columnName)
+		if err != nil {
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "column_type_changed", EsuiColumnTypeChanged{
+			TableName: tableName,
+			Name:      columnName,
+			Type:      columnType,
+		}, projection.Version)
+	})
+}
+
+// SetPrimaryKey sets the columns identifying a row of the table. Rows are
+// upserted by these columns.
+func (es *Esui) SetPrimaryKey(ctx context.Context, projectionID ShortID, tableName string, columns ...string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, table, err := es.loadTable(ctx, projectionID, tableName)
+		if err != nil {
+			return
+		}
+		err = table.checkKey(columns)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "primary_key_set", EsuiPrimaryKeySet{
+			TableName: tableName,
+			Columns:   columns,
+		}, projection.Version)
+	})
+}
+
+func (es *Esui) AddIndex(ctx context.Context, projectionID ShortID, tableName string, index EsuiIndex) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, table, err := es.loadTable(ctx, projectionID, tableName)
+		if err != nil {
+			return
+		}
+		if index.Name == "" {
+			err = ErrEmptyName
+			logger.Println(ctx, err)
+			return
+		}
+		if _, ok := table.Indexes[index.Name]; ok {
+			err = ErrIndexExists
+			logger.Println(ctx, err)
+			return
+		}
+		err = table.checkKey(index.Columns)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "index_added", EsuiIndexAdded{
+			TableName: tableName,
+			Index:     index,
+		}, projection.Version)
+	})
+}
+
+func (es *Esui) RemoveIndex(ctx context.Context, projectionID ShortID, tableName string, indexName string) (err error) {
+	return es.retry(ctx, func() (err error) {
+		projection, table, err := es.loadTable(ctx, projectionID, tableName)
+		if err != nil {
+			return
+		}
+		if _, ok := table.Indexes[indexName]; !ok {
+			err = ErrIndexNotFound
+			logger.Println(ctx, err)
+			return
+		}
+		return es.eventstore.StoreEvent(ctx, string(projectionID), "projection", "index_removed", EsuiIndexRemoved{
+			TableName: tableName,
+			Name:      indexName,
+		}, projection.Version)
+	})
+}
+
+func (projection *EsuiProjection) HandlePrimaryKeySet(event EstoreEvent) {
+	var primaryKeySet EsuiPrimaryKeySet
+	err := json.Unmarshal([]byte(event.Data), &primaryKeySet)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	table, ok := projection.Tables[primaryKeySet.TableName]
+	if !ok {
+		logger.Println("table not found")
+		return
+	}
+	table.PrimaryKey = primaryKeySet.Columns
+	projection.Tables[primaryKeySet.TableName] = table
+}
+
+func (projection *EsuiProjection) HandleIndexAdded(event EstoreEvent) {
+	var indexAdded EsuiIndexAdded
+	err := json.Unmarshal([]byte(event.Data), &indexAdded)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	table, ok := projection.Tables[indexAdded.TableName]
+	if !ok {
+		logger.Println("table not found")
+		return
+	}
+	if table.Indexes == nil {
+		table.Indexes = make(map[string]EsuiIndex)
+	}
+	table.Indexes[indexAdded.Index.Name] = indexAdded.Index
+	projection.Tables[indexAdded.TableName] = table
+}
+
+func (projection *EsuiProjection) HandleIndexRemoved(event EstoreEvent) {
+	var indexRemoved EsuiIndexRemoved
+	err := json.Unmarshal([]byte(event.Data), &indexRemoved)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	delete(projection.Tables[indexRemoved.TableName].Indexes, indexRemoved.Name)
+}
+
+func (projection *EsuiProjection) HandleTableDropped(event EstoreEvent) {
+	var tableDropped EsuiTableDropped
+	err := json.Unmarshal([]byte(event.Data), &tableDropped)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	delete(projection.Tables, tableDropped.Name)
+}
+
+func (projection *EsuiProjection) HandleTableRenamed(event EstoreEvent) {
+	var tableRenamed EsuiTableRenamed
+	err := json.Unmarshal([]byte(event.Data), &tableRenamed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	table, ok := projection.Tables[tableRenamed.Name]
+	if !ok {
+		logger.Println("table not found")
+		return
+	}
+	delete(projection.Tables, tableRenamed.Name)
+	table.Name = tableRenamed.NewName
+	projection.Tables[tableRenamed.NewName] = table
+}
+
+func (projection *EsuiProjection) HandleColumnDropped(event EstoreEvent) {
+	var columnDropped EsuiColumnDropped
+	err := json.Unmarshal([]byte(event.Data), &columnDropped)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	delete(projection.Tables[columnDropped.TableName].Columns, columnDropped.Name)
+}
+
+func (projection *EsuiProjection) HandleColumnRenamed(event EstoreEvent) {
+	var columnRenamed EsuiColumnRenamed
+	err := json.Unmarshal([]byte(event.Data), &columnRenamed)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	table, ok := projection.Tables[columnRenamed.TableName]
+	if !ok {
+		logger.Println("table not found")
+		return
+	}
+	column, ok := table.Columns[columnRenamed.Name]
+	if !ok {
+		logger.Println("column not found")
+		return
+	}
+	delete(table.Columns, columnRenamed.Name)
+	column.Name = columnRenamed.NewName
+	table.Columns[columnRenamed.NewName] = column
+
+	rename := func(columns []string) {
+		for i, name := range columns {
+			if name == columnRenamed.Name {
+				columns[i] = columnRenamed.NewName
+			}
+		}
+	}
+	rename(table.PrimaryKey)
+	for _, index := range table.Indexes {
+		rename(index.Columns)
+	}
+}
+
+func (projection *EsuiProjection) HandleColumnTypeChanged(event EstoreEvent) {
+	var typeChanged EsuiColumnTypeChanged
+	err := json.Unmarshal([]byte(event.Data), &typeChanged)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	table, ok := projection.Tables[typeChanged.TableName]
+	if !ok {
+		logger.Println("table not found")
+		return
+	}
+	column, ok := table.Columns[typeChanged.Name]
+	if !ok {
+		logger.Println("column not found")
+		return
+	}
+	column.Type = typeChanged.Type
+	table.Columns[typeChanged.Name] = column
+}
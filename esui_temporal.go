@@ -0,0 +1,89 @@
+package esui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ariefsam/esui/logger"
+)
+
+// PointInTime tells where GetEntityAt and GetProjectionAt stop replaying.
+// With EventID the replay stops after that event of the aggregate, with
+// Time after the last event stored at or before it. The zero value replays
+// everything.
+type PointInTime struct {
+	EventID ShortID
+	Time    time.Time
+}
+
+func AtEvent(eventID ShortID) PointInTime {
+	return PointInTime{EventID: eventID}
+}
+
+func AtTime(t time.Time) PointInTime {
+	return PointInTime{Time: t}
+}
+
+// cut returns how many of the aggregate events are replayed up to the
+// point.
+func (at PointInTime) cut(events []EstoreEvent) (n int, err error) {
+	if at.EventID != "" {
+		for i, event := range events {
+			if event.EventID == at.EventID {
+				return i + 1, nil
+			}
+		}
+		err = fmt.Errorf("%w: %s", ErrEventNotFound, at.EventID)
+		return
+	}
+	if at.Time.IsZero() {
+		return len(events), nil
+	}
+	for n < len(events) && !events[n].Timestamp.After(at.Time) {
+		n++
+	}
+	return
+}
+
+// GetEntityAt is GetEntity as the entity was at the point. Version counts
+// the replayed events only.
+func (es *Esui) GetEntityAt(ctx context.Context, entityID ShortID, at PointInTime) (entity EsuiEntity, err error) {
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(entityID), "entity", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	n, err := at.cut(events)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	for _, event := range events[:n] {
+		entity.apply(event, entityID)
+	}
+	entity.Version = int64(n)
+	return
+}
+
+// GetProjectionAt is GetProjection as the projection was at the point, see
+// GetEntityAt.
+func (es *Esui) GetProjectionAt(ctx context.Context, projectionID ShortID, at PointInTime) (projection EsuiProjection, err error) {
+	events, err := es.eventstore.FetchAggregateEvents(ctx, string(projectionID), "projection", "")
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	n, err := at.cut(events)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	for _, event := range events[:n] {
+		projection.apply(event, projectionID)
+	}
+	projection.Version = int64(n)
+	return
+}
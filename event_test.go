@@ -3,10 +3,11 @@ package esui_test
 import (
 	"context"
 	"errors"
-	"log"
 	"testing"
 
 	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -15,8 +16,8 @@ type mockEventstore struct {
 	mock.Mock
 }
 
-func (m *mockEventstore) StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}) (err error) {
-	args := m.Called(aggregateID, aggregateName, eventName, data)
+func (m *mockEventstore) StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}, expectedVersion int64) (err error) {
+	args := m.Called(aggregateID, aggregateName, eventName, data, expectedVersion)
 	if len(args) == 0 {
 		return nil
 	}
@@ -33,6 +34,16 @@ func (m *mockEventstore) FetchAggregateEvents(ctx context.Context, aggregateID s
 	return
 }
 
+func (m *mockEventstore) FetchAllEvents(ctx context.Context, fromPosition int64, limit int) (events []esui.EstoreEvent, err error) {
+	args := m.Called(fromPosition, limit)
+	if len(args) == 0 {
+		return []esui.EstoreEvent{}, nil
+	}
+	events, _ = args.Get(0).([]esui.EstoreEvent)
+	err = args.Error(1)
+	return
+}
+
 type mockIDGenerator struct {
 	mock.Mock
 }
@@ -48,13 +59,14 @@ func TestNewEntity(t *testing.T) {
 	idgenerator := &mockIDGenerator{}
 	esObj := esui.NewEsui(estore, idgenerator)
 	require.NotNil(t, esObj)
+	estore.On("FetchAllEvents", int64(0), 500).Return([]esui.EstoreEvent{}, nil)
 
 	t.Run("Create Entity Success", func(t *testing.T) {
 		idgenerator.On("Generate").Return("abc123").Once()
 		expectedEntityObj := esui.EsuiEntityCreated{
 			Name: "user",
 		}
-		estore.On("StoreEvent", "abc123", "entity", "created", expectedEntityObj).Return(nil)
+		estore.On("StoreEvent", "abc123", "entity", "created", expectedEntityObj, int64(0)).Return(nil)
 
 		entityID, err := esObj.CreateEntity(ctx, "user")
 		require.NoError(t, err)
@@ -66,7 +78,7 @@ func TestNewEntity(t *testing.T) {
 				return false
 			}
 			return actualEntity.Name == expectedEntityObj.Name
-		}))
+		}), int64(0))
 
 		require.NoError(t, err)
 	})
@@ -76,7 +88,7 @@ func TestNewEntity(t *testing.T) {
 		expectedEntityObj := esui.EsuiEntityCreated{
 			Name: "userx",
 		}
-		estore.On("StoreEvent", "abc123", "entity", "created", expectedEntityObj).Return(errors.New("Error store event"))
+		estore.On("StoreEvent", "abc123", "entity", "created", expectedEntityObj, int64(0)).Return(errors.New("Error store event"))
 
 		entityID, err := esObj.CreateEntity(ctx, "userx")
 		require.Error(t, err)
@@ -145,7 +157,7 @@ func TestAddEventToEntity(t *testing.T) {
 
 		estore.On("StoreEvent", "abc123", "entity", "event_added", esui.EsuiEventAdded{
 			Name: "user_created",
-		}).Return(nil).Once()
+		}, int64(1)).Return(nil).Once()
 		err := esObj.AddEventToEntity(ctx, "abc123", "user_created")
 		require.NoError(t, err)
 
@@ -155,7 +167,7 @@ func TestAddEventToEntity(t *testing.T) {
 				return false
 			}
 			return dataEvent.Name == "user_created"
-		}))
+		}), int64(1))
 	})
 
 	t.Run("Get entity will show event", func(t *testing.T) {
@@ -232,10 +244,10 @@ func TestAddAttribute(t *testing.T) {
 		}, nil).Once()
 
 		estore.On("StoreEvent", "prod123", "entity", "attribute_added", esui.EsuiAttributeAdded{
-			EventName: "product_created",
-			Name:      "name",
-			Type:      "string",
-		}).Return(nil).Once()
+			EventName:       "product_created",
+			Name:            "name",
+			AttributeSchema: esui.AttributeSchema{Type: "string"},
+		}, int64(2)).Return(nil).Once()
 
 		err := esObj.AddAttribute(ctx, "prod123", "product_created", "name", "string")
 		require.NoError(t, err)
@@ -246,7 +258,7 @@ func TestAddAttribute(t *testing.T) {
 				return false
 			}
 			return dataEvent.Name == "name" && dataEvent.Type == "string" && dataEvent.EventName == "product_created"
-		}))
+		}), int64(2))
 	})
 
 	t.Run("Get entity will show attribute", func(t *testing.T) {
@@ -287,9 +299,167 @@ func TestAddAttribute(t *testing.T) {
 		require.Equal(t, esui.AttributeType("float"), esuiEntity.Events["product_created"].Attributes["price"])
 	})
 
+	t.Run("Add Attribute Validation", func(t *testing.T) {
+		estore.On("FetchAggregateEvents", "prod125", "entity", "").Return([]esui.EstoreEvent{
+			{
+				EventID:       "abc123",
+				AggregateID:   "prod125",
+				AggregateName: "entity",
+				EventName:     "created",
+				Data:          `{"name":"product"}`,
+			},
+			{
+				EventID:       "abc124",
+				AggregateID:   "prod125",
+				AggregateName: "entity",
+				EventName:     "event_added",
+				Data:          `{"name":"product_created"}`,
+			},
+			{
+				EventID:       "abc125",
+				AggregateID:   "prod125",
+				AggregateName: "entity",
+				EventName:     "attribute_added",
+				Data:          `{"event_name":"product_created","name":"name","type":"string"}`,
+			},
+		}, nil)
+		estore.On("FetchAggregateEvents", "unknown", "entity", "").Return([]esui.EstoreEvent{}, nil)
+
+		err := esObj.AddAttribute(ctx, "prod125", "product_created", "price", "money")
+		require.ErrorIs(t, err, esui.ErrInvalidType)
+
+		err = esObj.AddAttribute(ctx, "unknown", "product_created", "price", "int")
+		require.ErrorIs(t, err, esui.ErrEntityNotFound)
+
+		err = esObj.AddAttribute(ctx, "prod125", "product_deleted", "price", "int")
+		require.ErrorIs(t, err, esui.ErrEventNotFound)
+
+		err = esObj.AddAttribute(ctx, "prod125", "product_created", "name", "string")
+		require.ErrorIs(t, err, esui.ErrAttributeExists)
+
+		estore.AssertNotCalled(t, "StoreEvent", "prod125", "entity", "attribute_added", mock.Anything, mock.Anything)
+	})
+
 }
 
 func TestProjectionApplication(t *testing.T) {
-	currentApp := esui.Application{}
-	log.Println(currentApp)
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	esObj := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := esObj.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, esObj.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, esObj.AddAttribute(ctx, entityID, "product_created", "name", esui.TypeString))
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := esObj.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+	require.NoError(t, esObj.CreateTable(ctx, projectionID, "products"))
+	require.NoError(t, esObj.AddColumn(ctx, projectionID, "products", "name", "string"))
+	require.NoError(t, esObj.SubscribeProjection(ctx, projectionID, entityID, "product_created"))
+
+	idgenerator.On("Generate").Return("app1").Once()
+	applicationID, err := esObj.CreateApplication(ctx, "shop")
+	require.NoError(t, err)
+	require.NoError(t, esObj.AttachEntity(ctx, applicationID, entityID))
+	require.NoError(t, esObj.AttachProjection(ctx, applicationID, projectionID))
+
+	assert.ErrorIs(t, esObj.AttachEntity(ctx, applicationID, entityID), esui.ErrAlreadyAttached)
+	assert.ErrorIs(t, esObj.AttachEntity(ctx, applicationID, "unknown"), esui.ErrEntityNotFound)
+	assert.ErrorIs(t, esObj.AttachProjection(ctx, applicationID, "unknown"), esui.ErrProjectionNotFound)
+	assert.ErrorIs(t, esObj.AttachEntity(ctx, "unknown", entityID), esui.ErrApplicationNotFound)
+
+	currentApp, err := esObj.GetApplication(ctx, applicationID)
+	require.NoError(t, err)
+	assert.Equal(t, esui.Application{
+		ID:   "app1",
+		Name: "shop",
+		Entity: map[esui.EntityID]esui.Entity{
+			"prod1": {
+				Name: "product",
+				Events: map[esui.ShortID]esui.Event{
+					"product_created": {
+						Name:      "product_created",
+						Attribute: map[esui.AttributeName]esui.AttributeType{"name": esui.TypeString},
+					},
+				},
+			},
+		},
+		Projections: map[esui.ProjectionID]esui.Projection{
+			"proj1": {
+				ID:   "proj1",
+				Name: "product_list",
+				SubscribeTo: map[esui.EntityID]map[esui.EntityEventName]bool{
+					"prod1": {"product_created": true},
+				},
+				Tables: []esui.Table{
+					{Name: "products", Columns: map[esui.AttributeName]esui.AttributeType{"name": "string"}},
+				},
+			},
+		},
+	}, currentApp)
+}
+
+func TestAddEventToEntityRetry(t *testing.T) {
+	ctx := context.TODO()
+	estore := &mockEventstore{}
+	idgenerator := &mockIDGenerator{}
+	esObj := esui.NewEsui(estore, idgenerator, esui.WithRetryPolicy(esui.RetryPolicy{MaxAttempts: 2}))
+
+	estore.On("FetchAggregateEvents", "abc123", "entity", "").Return(
+		[]esui.EstoreEvent{
+			{
+				EventID:       "abc123",
+				AggregateID:   "abc123",
+				AggregateName: "entity",
+				EventName:     "created",
+				Data:          `{"name":"user"}`,
+			},
+		}, nil).Once()
+	// The retry reloads the entity and expects the version it now has.
+	estore.On("FetchAggregateEvents", "abc123", "entity", "").Return(
+		[]esui.EstoreEvent{
+			{
+				EventID:       "abc123",
+				AggregateID:   "abc123",
+				AggregateName: "entity",
+				EventName:     "created",
+				Data:          `{"name":"user"}`,
+			},
+			{
+				EventID:       "abc125",
+				AggregateID:   "abc123",
+				AggregateName: "entity",
+				EventName:     "event_added",
+				Data:          `{"name":"user_deleted"}`,
+			},
+		}, nil).Once()
+
+	conflict := &esui.ConcurrencyError{AggregateID: "abc123", AggregateName: "entity", ExpectedVersion: 1, ActualVersion: 2}
+	estore.On("StoreEvent", "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, int64(1)).Return(conflict).Once()
+	estore.On("StoreEvent", "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, int64(2)).Return(nil).Once()
+
+	err := esObj.AddEventToEntity(ctx, "abc123", "user_created")
+	require.NoError(t, err)
+	estore.AssertNumberOfCalls(t, "StoreEvent", 2)
+
+	t.Run("Without Retry Policy", func(t *testing.T) {
+		esObj := esui.NewEsui(estore, idgenerator)
+		estore.On("FetchAggregateEvents", "abc124", "entity", "").Return(
+			[]esui.EstoreEvent{
+				{
+					EventID:       "abc124",
+					AggregateID:   "abc124",
+					AggregateName: "entity",
+					EventName:     "created",
+					Data:          `{"name":"user"}`,
+				},
+			}, nil).Once()
+		estore.On("StoreEvent", "abc124", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, int64(1)).Return(conflict).Once()
+
+		err := esObj.AddEventToEntity(ctx, "abc124", "user_created")
+		require.ErrorIs(t, err, esui.ErrConcurrency)
+	})
 }
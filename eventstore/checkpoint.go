@@ -0,0 +1,33 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryCheckpoints keeps subscription checkpoints in process memory.
+type MemoryCheckpoints struct {
+	mu        sync.RWMutex
+	positions map[string]int64
+}
+
+func NewMemoryCheckpoints() (obj *MemoryCheckpoints) {
+	obj = &MemoryCheckpoints{
+		positions: make(map[string]int64),
+	}
+	return obj
+}
+
+func (c *MemoryCheckpoints) LoadCheckpoint(ctx context.Context, name string) (position int64, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	position = c.positions[name]
+	return
+}
+
+func (c *MemoryCheckpoints) SaveCheckpoint(ctx context.Context, name string, position int64) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.positions[name] = position
+	return
+}
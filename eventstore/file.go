@@ -0,0 +1,300 @@
+package eventstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/logger"
+)
+
+var ErrCorruptFile = errors.New("corrupt event file")
+
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs the file after every stored event.
+	SyncAlways SyncPolicy = iota
+	// SyncNever leaves flushing to the OS, call Sync or Close to force it.
+	SyncNever
+)
+
+type FileOption func(*File)
+
+func WithSyncPolicy(policy SyncPolicy) FileOption {
+	return func(f *File) {
+		f.syncPolicy = policy
+	}
+}
+
+type fileRecord struct {
+	eventID  string
+	position int64
+	offset   int64
+	length   int
+}
+
+// File persists events as JSON lines appended to a single file. The offset
+// of every line is indexed per aggregate when the file is opened, so fetching
+// an aggregate only reads its own lines.
+type File struct {
+	mu         sync.RWMutex
+	file       *os.File
+	syncPolicy SyncPolicy
+	sequence   int64
+	size       int64
+	all        []fileRecord
+	aggregates map[string][]fileRecord
+	changed    chan struct{}
+}
+
+func OpenFile(path string, options ...FileOption) (obj *File, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+
+	obj = &File{
+		file:       file,
+		syncPolicy: SyncAlways,
+		aggregates: make(map[string][]fileRecord),
+		changed:    make(chan struct{}),
+	}
+	for _, option := range options {
+		option(obj)
+	}
+
+	err = obj.recover()
+	if err != nil {
+		logger.Println(err)
+		file.Close()
+		obj = nil
+		return
+	}
+	return
+}
+
+// recover rebuilds the index from the file. A last line that was torn by a
+// crash in the middle of a write is truncated away, anything unreadable
+// before it is reported as ErrCorruptFile.
+func (f *File) recover() (err error) {
+	reader := bufio.NewReader(f.file)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if len(line) == 0 {
+			break
+		}
+
+		var event esui.EstoreEvent
+		complete := line[len(line)-1] == '\n'
+		if !complete || json.Unmarshal(bytes.TrimSpace(line), &event) != nil {
+			if readErr == io.EOF {
+				logger.Println("truncating torn event at offset", offset)
+				err = f.file.Truncate(offset)
+				if err != nil {
+					return
+				}
+				break
+			}
+			return ErrCorruptFile
+		}
+
+		f.index(event, offset, len(line))
+		offset += int64(len(line))
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	f.size = offset
+	return
+}
+
+// index records where an event lives in the file. Positions follow line
+// order, so they stay stable across reopens.
+func (f *File) index(event esui.EstoreEvent, offset int64, length int) {
+	key := aggregateKey(string(event.AggregateID), event.AggregateName)
+	record := fileRecord{
+		eventID:  string(event.EventID),
+		position: int64(len(f.all)) + 1,
+		offset:   offset,
+		length:   length,
+	}
+	f.all = append(f.all, record)
+	f.aggregates[key] = append(f.aggregates[key], record)
+	if sequence, err := strconv.ParseInt(string(event.EventID), 10, 64); err == nil && sequence > f.sequence {
+		f.sequence = sequence
+	}
+}
+
+func (f *File) StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}, expectedVersion int64) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err = checkVersion(aggregateID, aggregateName, expectedVersion, int64(len(f.aggregates[aggregateKey(aggregateID, aggregateName)])))
+	if err != nil {
+		return
+	}
+
+	event := esui.EstoreEvent{
+		EventID:       esui.ShortID(strconv.FormatInt(f.sequence+1, 10)),
+		Position:      int64(len(f.all)) + 1,
+		AggregateID:   esui.ShortID(aggregateID),
+		AggregateName: aggregateName,
+		EventName:     eventName,
+		Data:          string(payload),
+		Timestamp:     time.Now().UTC(),
+		Metadata:      esui.MetadataFromContext(ctx),
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	line = append(line, '\n')
+
+	_, err = f.file.WriteAt(line, f.size)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if f.syncPolicy == SyncAlways {
+		err = f.file.Sync()
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+	}
+
+	f.index(event, f.size, len(line))
+	f.size += int64(len(line))
+
+	close(f.changed)
+	f.changed = make(chan struct{})
+	return
+}
+
+func (f *File) FetchAggregateEvents(ctx context.Context, aggregateID string, aggregateName string, fromID string) (events []esui.EstoreEvent, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	records := f.aggregates[aggregateKey(aggregateID, aggregateName)]
+	start := 0
+	if fromID != "" {
+		start = -1
+		for i, record := range records {
+			if record.eventID == fromID {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			err = ErrEventNotFound
+			return
+		}
+	}
+
+	events, err = f.read(records[start:])
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+func (f *File) read(records []fileRecord) (events []esui.EstoreEvent, err error) {
+	events = make([]esui.EstoreEvent, 0, len(records))
+	for _, record := range records {
+		line := make([]byte, record.length)
+		_, err = f.file.ReadAt(line, record.offset)
+		if err != nil {
+			return nil, err
+		}
+
+		var event esui.EstoreEvent
+		err = json.Unmarshal(line, &event)
+		if err != nil {
+			return nil, err
+		}
+		event.Position = record.position
+		events = append(events, event)
+	}
+	return
+}
+
+// FetchAllEvents returns events of every aggregate with a position after
+// fromPosition, in file order. A limit of zero or less returns everything.
+func (f *File) FetchAllEvents(ctx context.Context, fromPosition int64, limit int) (events []esui.EstoreEvent, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	start := int(max(fromPosition, 0))
+	end := len(f.all)
+	if start > end {
+		start = end
+	}
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	events, err = f.read(f.all[start:end])
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// Changed returns a channel that is closed the next time an event is stored.
+func (f *File) Changed() <-chan struct{} {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.changed
+}
+
+func (f *File) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync()
+}
+
+func (f *File) Close() (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err = f.file.Sync()
+	if err != nil {
+		f.file.Close()
+		return
+	}
+	return f.file.Close()
+}
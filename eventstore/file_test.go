@@ -0,0 +1,109 @@
+package eventstore_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStoreAndReopen(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, err := eventstore.OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreEvent(esui.WithActor(ctx, "arief"), "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, esui.AnyVersion))
+	require.NoError(t, store.StoreEvent(ctx, "xyz123", "projection", "created", esui.EsuiProjectionCreated{Name: "projection1"}, esui.AnyVersion))
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, esui.AnyVersion))
+	require.NoError(t, store.Close())
+
+	store, err = eventstore.OpenFile(path, eventstore.WithSyncPolicy(eventstore.SyncNever))
+	require.NoError(t, err)
+	defer store.Close()
+
+	events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "created", events[0].EventName)
+	assert.Equal(t, `{"name":"user"}`, events[0].Data)
+	assert.Equal(t, "event_added", events[1].EventName)
+	assert.WithinDuration(t, time.Now(), events[0].Timestamp, time.Minute)
+	assert.Equal(t, esui.EventMetadata{ActorID: "arief"}, events[0].Metadata)
+
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_deleted"}, esui.AnyVersion))
+	events, err = store.FetchAggregateEvents(ctx, "abc123", "entity", string(events[1].EventID))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.EqualValues(t, "4", events[0].EventID)
+	assert.Equal(t, `{"name":"user_deleted"}`, events[0].Data)
+}
+
+func TestFileRecoverTornLine(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, err := eventstore.OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, esui.AnyVersion))
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"event_id":"2","aggregate_id":"abc1`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = eventstore.OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, esui.AnyVersion))
+	require.NoError(t, store.Close())
+
+	store, err = eventstore.OpenFile(path)
+	require.NoError(t, err)
+	defer store.Close()
+	events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "event_added", events[1].EventName)
+}
+
+func TestFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("garbage\n{\"event_id\":\"1\"}\n"), 0644))
+
+	_, err := eventstore.OpenFile(path)
+	assert.ErrorIs(t, err, eventstore.ErrCorruptFile)
+}
+
+func TestFileFetchAllEvents(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	store, err := eventstore.OpenFile(path)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "xyz123", "projection", "created", esui.EsuiProjectionCreated{Name: "projection1"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, 1))
+	require.NoError(t, store.Close())
+
+	store, err = eventstore.OpenFile(path)
+	require.NoError(t, err)
+	defer store.Close()
+
+	events, err := store.FetchAllEvents(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.EqualValues(t, 2, events[0].Position)
+	assert.Equal(t, "projection", events[0].AggregateName)
+
+	events, err = store.FetchAllEvents(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
@@ -0,0 +1,155 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/logger"
+)
+
+var ErrEventNotFound = errors.New("event not found")
+
+// Memory keeps every event in process memory. It is safe for concurrent use
+// and is meant for tests and demos, nothing survives a restart.
+type Memory struct {
+	mu         sync.RWMutex
+	sequence   int64
+	events     []esui.EstoreEvent
+	aggregates map[string][]int
+	changed    chan struct{}
+}
+
+func NewMemory() (obj *Memory) {
+	obj = &Memory{
+		aggregates: make(map[string][]int),
+		changed:    make(chan struct{}),
+	}
+	return obj
+}
+
+func aggregateKey(aggregateID string, aggregateName string) string {
+	return aggregateName + "/" + aggregateID
+}
+
+func checkVersion(aggregateID string, aggregateName string, expectedVersion int64, actualVersion int64) error {
+	if expectedVersion == esui.AnyVersion || expectedVersion == actualVersion {
+		return nil
+	}
+	return &esui.ConcurrencyError{
+		AggregateID:     aggregateID,
+		AggregateName:   aggregateName,
+		ExpectedVersion: expectedVersion,
+		ActualVersion:   actualVersion,
+	}
+}
+
+func (m *Memory) StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}, expectedVersion int64) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := aggregateKey(aggregateID, aggregateName)
+	err = checkVersion(aggregateID, aggregateName, expectedVersion, int64(len(m.aggregates[key])))
+	if err != nil {
+		return
+	}
+
+	m.sequence++
+	event := esui.EstoreEvent{
+		EventID:       esui.ShortID(strconv.FormatInt(m.sequence, 10)),
+		Position:      m.sequence,
+		AggregateID:   esui.ShortID(aggregateID),
+		AggregateName: aggregateName,
+		EventName:     eventName,
+		Data:          string(payload),
+		Timestamp:     time.Now().UTC(),
+		Metadata:      esui.MetadataFromContext(ctx),
+	}
+
+	m.aggregates[key] = append(m.aggregates[key], len(m.events))
+	m.events = append(m.events, event)
+
+	close(m.changed)
+	m.changed = make(chan struct{})
+	return
+}
+
+// FetchAggregateEvents returns the events of one aggregate in the order they
+// were stored. When fromID is set only the events after that event are
+// returned.
+func (m *Memory) FetchAggregateEvents(ctx context.Context, aggregateID string, aggregateName string, fromID string) (events []esui.EstoreEvent, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	indexes := m.aggregates[aggregateKey(aggregateID, aggregateName)]
+	start := 0
+	if fromID != "" {
+		start = -1
+		for i, idx := range indexes {
+			if string(m.events[idx].EventID) == fromID {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			err = ErrEventNotFound
+			return
+		}
+	}
+
+	events = make([]esui.EstoreEvent, 0, len(indexes)-start)
+	for _, idx := range indexes[start:] {
+		events = append(events, m.events[idx])
+	}
+	return
+}
+
+// FetchAllEvents returns events of every aggregate with a position after
+// fromPosition, in the order they were stored. A limit of zero or less
+// returns everything.
+func (m *Memory) FetchAllEvents(ctx context.Context, fromPosition int64, limit int) (events []esui.EstoreEvent, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := int(max(fromPosition, 0))
+	end := len(m.events)
+	if start > end {
+		start = end
+	}
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	events = make([]esui.EstoreEvent, end-start)
+	copy(events, m.events[start:end])
+	return
+}
+
+// Changed returns a channel that is closed the next time an event is stored.
+func (m *Memory) Changed() <-chan struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.changed
+}
@@ -0,0 +1,113 @@
+package eventstore_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/ariefsam/esui/idgenerator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type shortIDGenerator struct{}
+
+func (shortIDGenerator) Generate() string {
+	return idgenerator.Generate()
+}
+
+func TestMemoryStoreAndFetch(t *testing.T) {
+	ctx := context.TODO()
+	store := eventstore.NewMemory()
+
+	err := store.StoreEvent(ctx, "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, 0)
+	require.NoError(t, err)
+	err = store.StoreEvent(ctx, "xyz123", "projection", "created", esui.EsuiProjectionCreated{Name: "projection1"}, esui.AnyVersion)
+	require.NoError(t, err)
+	err = store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, esui.AnyVersion)
+	require.NoError(t, err)
+
+	events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "created", events[0].EventName)
+	assert.Equal(t, `{"name":"user"}`, events[0].Data)
+	assert.Equal(t, "event_added", events[1].EventName)
+	assert.NotEqual(t, events[0].EventID, events[1].EventID)
+
+	t.Run("From ID", func(t *testing.T) {
+		events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", string(events[0].EventID))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "event_added", events[0].EventName)
+	})
+
+	t.Run("From Unknown ID", func(t *testing.T) {
+		_, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "unknown")
+		assert.ErrorIs(t, err, eventstore.ErrEventNotFound)
+	})
+
+	t.Run("Unknown Aggregate", func(t *testing.T) {
+		events, err := store.FetchAggregateEvents(ctx, "abc123", "projection", "")
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
+
+func TestMemoryConcurrentStore(t *testing.T) {
+	ctx := context.TODO()
+	store := eventstore.NewMemory()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: fmt.Sprint("event", i)}, esui.AnyVersion)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	assert.Len(t, events, 50)
+}
+
+func TestMemoryWithEsui(t *testing.T) {
+	ctx := context.TODO()
+	es := esui.NewEsui(eventstore.NewMemory(), shortIDGenerator{})
+
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "name", "string"))
+	assert.Error(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+
+	entity, err := es.GetEntity(ctx, entityID)
+	require.NoError(t, err)
+	assert.Equal(t, "product", entity.Name)
+	assert.Equal(t, esui.AttributeType("string"), entity.Events["product_created"].Attributes["name"])
+}
+
+func TestMemoryExpectedVersion(t *testing.T) {
+	ctx := context.TODO()
+	store := eventstore.NewMemory()
+
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, 1))
+
+	err := store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, 1)
+	require.ErrorIs(t, err, esui.ErrConcurrency)
+	var conflict *esui.ConcurrencyError
+	require.ErrorAs(t, err, &conflict)
+	assert.EqualValues(t, 1, conflict.ExpectedVersion)
+	assert.EqualValues(t, 2, conflict.ActualVersion)
+
+	events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
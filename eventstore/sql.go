@@ -0,0 +1,349 @@
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/logger"
+)
+
+type SQLOption func(*SQL)
+
+// WithDollarPlaceholders makes queries use $1, $2, ... instead of ?, for
+// drivers such as PostgreSQL.
+func WithDollarPlaceholders() SQLOption {
+	return func(s *SQL) {
+		s.dollarPlaceholders = true
+	}
+}
+
+// SQL stores events in a table of any database/sql database. The schema is
+// created and migrated by OpenSQL.
+type SQL struct {
+	db                 *sql.DB
+	dollarPlaceholders bool
+}
+
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS esui_events (
+		sequence INTEGER PRIMARY KEY,
+		event_id VARCHAR(64) NOT NULL UNIQUE,
+		aggregate_id VARCHAR(64) NOT NULL,
+		aggregate_name VARCHAR(64) NOT NULL,
+		event_name VARCHAR(64) NOT NULL,
+		data TEXT NOT NULL,
+		timestamp BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS esui_events_aggregate ON esui_events (aggregate_name, aggregate_id, sequence)`,
+	`ALTER TABLE esui_events ADD COLUMN version BIGINT NOT NULL DEFAULT 0`,
+	`UPDATE esui_events SET version = (
+		SELECT COUNT(*) FROM esui_events previous
+		WHERE previous.aggregate_name = esui_events.aggregate_name
+		AND previous.aggregate_id = esui_events.aggregate_id
+		AND previous.sequence <= esui_events.sequence
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS esui_events_version ON esui_events (aggregate_name, aggregate_id, version)`,
+	`CREATE TABLE IF NOT EXISTS esui_checkpoints (
+		name VARCHAR(128) PRIMARY KEY,
+		position BIGINT NOT NULL
+	)`,
+	`ALTER TABLE esui_events ADD COLUMN actor_id VARCHAR(128) NOT NULL DEFAULT ''`,
+	`ALTER TABLE esui_events ADD COLUMN correlation_id VARCHAR(128) NOT NULL DEFAULT ''`,
+	`ALTER TABLE esui_events ADD COLUMN causation_id VARCHAR(128) NOT NULL DEFAULT ''`,
+}
+
+func OpenSQL(ctx context.Context, db *sql.DB, options ...SQLOption) (obj *SQL, err error) {
+	obj = &SQL{
+		db: db,
+	}
+	for _, option := range options {
+		option(obj)
+	}
+
+	err = obj.migrate(ctx)
+	if err != nil {
+		logger.Println(ctx, err)
+		obj = nil
+		return
+	}
+	return
+}
+
+// migrate runs every migration newer than the version recorded in
+// esui_schema_migrations, each in its own transaction.
+func (s *SQL) migrate(ctx context.Context) (err error) {
+	_, err = s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS esui_schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return
+	}
+
+	var version int
+	err = s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM esui_schema_migrations`).Scan(&version)
+	if err != nil {
+		return
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		var tx *sql.Tx
+		tx, err = s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return
+		}
+		_, err = tx.ExecContext(ctx, sqlMigrations[i])
+		if err == nil {
+			_, err = tx.ExecContext(ctx, s.query(`INSERT INTO esui_schema_migrations (version) VALUES (?)`), i+1)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *SQL) query(query string) string {
+	if !s.dollarPlaceholders {
+		return query
+	}
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// sqlWriteAttempts bounds how often StoreEvent retries a write that failed
+// only because a concurrent writer got in the way.
+const sqlWriteAttempts = 20
+
+func (s *SQL) StoreEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, data interface{}, expectedVersion int64) (err error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = s.storeEvent(ctx, aggregateID, aggregateName, eventName, payload, expectedVersion)
+		if err == nil || attempt == sqlWriteAttempts || !transient(err) {
+			return
+		}
+		logger.Println(ctx, err)
+
+		backoff := time.Millisecond << min(attempt, 6)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rand.N(backoff) + time.Millisecond):
+		}
+	}
+}
+
+func (s *SQL) storeEvent(ctx context.Context, aggregateID string, aggregateName string, eventName string, payload []byte, expectedVersion int64) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	version, err := s.version(ctx, tx, aggregateID, aggregateName)
+	if err != nil {
+		return
+	}
+	err = checkVersion(aggregateID, aggregateName, expectedVersion, version)
+	if err != nil {
+		return
+	}
+
+	var sequence int64
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(sequence), 0) + 1 FROM esui_events`).Scan(&sequence)
+	if err != nil {
+		return
+	}
+
+	metadata := esui.MetadataFromContext(ctx)
+	_, err = tx.ExecContext(ctx, s.query(`INSERT INTO esui_events
+		(sequence, event_id, aggregate_id, aggregate_name, event_name, data, timestamp, version, actor_id, correlation_id, causation_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		sequence, strconv.FormatInt(sequence, 10), aggregateID, aggregateName, eventName, string(payload), time.Now().UTC().UnixNano(), version+1,
+		metadata.ActorID, metadata.CorrelationID, metadata.CausationID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil && expectedVersion != esui.AnyVersion {
+		// A concurrent writer may have taken the same version first.
+		if actual, versionErr := s.version(ctx, s.db, aggregateID, aggregateName); versionErr == nil && actual != version {
+			err = checkVersion(aggregateID, aggregateName, expectedVersion, actual)
+		}
+	}
+	return
+}
+
+// transient tells whether a write failed only because a concurrent writer
+// locked the database or took the sequence or version it picked, so trying
+// again can succeed. Errors are recognized through the Code method of
+// modernc.org/sqlite and the SQLState method of PostgreSQL drivers.
+func transient(err error) bool {
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		switch code := sqliteErr.Code(); {
+		case code&0xff == sqliteBusy, code&0xff == sqliteLocked:
+			return true
+		case code == sqliteConstraintPrimaryKey, code == sqliteConstraintUnique:
+			return true
+		}
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case pgUniqueViolation, pgSerializationFailure, pgDeadlockDetected:
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	sqliteBusy                 = 5
+	sqliteLocked               = 6
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+
+	pgUniqueViolation      = "23505"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *SQL) version(ctx context.Context, db queryRower, aggregateID string, aggregateName string) (version int64, err error) {
+	err = db.QueryRowContext(ctx, s.query(`SELECT COALESCE(MAX(version), 0) FROM esui_events
+		WHERE aggregate_name = ? AND aggregate_id = ?`),
+		aggregateName, aggregateID).Scan(&version)
+	return
+}
+
+func (s *SQL) FetchAggregateEvents(ctx context.Context, aggregateID string, aggregateName string, fromID string) (events []esui.EstoreEvent, err error) {
+	var fromSequence int64
+	if fromID != "" {
+		err = s.db.QueryRowContext(ctx, s.query(`SELECT sequence FROM esui_events
+			WHERE event_id = ? AND aggregate_id = ? AND aggregate_name = ?`),
+			fromID, aggregateID, aggregateName).Scan(&fromSequence)
+		if err == sql.ErrNoRows {
+			err = ErrEventNotFound
+			return
+		}
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT event_id, sequence, aggregate_id, aggregate_name, event_name, data, timestamp, actor_id, correlation_id, causation_id FROM esui_events
+		WHERE aggregate_name = ? AND aggregate_id = ? AND sequence > ?
+		ORDER BY sequence`),
+		aggregateName, aggregateID, fromSequence)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	events, err = scanEvents(rows)
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+func scanEvents(rows *sql.Rows) (events []esui.EstoreEvent, err error) {
+	defer rows.Close()
+
+	events = []esui.EstoreEvent{}
+	for rows.Next() {
+		var event esui.EstoreEvent
+		var timestamp int64
+		err = rows.Scan(&event.EventID, &event.Position, &event.AggregateID, &event.AggregateName, &event.EventName, &event.Data, &timestamp,
+			&event.Metadata.ActorID, &event.Metadata.CorrelationID, &event.Metadata.CausationID)
+		if err != nil {
+			return nil, err
+		}
+		event.Timestamp = time.Unix(0, timestamp).UTC()
+		events = append(events, event)
+	}
+	err = rows.Err()
+	return
+}
+
+// FetchAllEvents returns events of every aggregate with a position after
+// fromPosition, ordered by sequence. A limit of zero or less returns
+// everything.
+func (s *SQL) FetchAllEvents(ctx context.Context, fromPosition int64, limit int) (events []esui.EstoreEvent, err error) {
+	query := `SELECT event_id, sequence, aggregate_id, aggregate_name, event_name, data, timestamp, actor_id, correlation_id, causation_id FROM esui_events
+		WHERE sequence > ?
+		ORDER BY sequence`
+	args := []any{fromPosition}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query(query), args...)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	events, err = scanEvents(rows)
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+func (s *SQL) LoadCheckpoint(ctx context.Context, name string) (position int64, err error) {
+	err = s.db.QueryRowContext(ctx, s.query(`SELECT position FROM esui_checkpoints WHERE name = ?`), name).Scan(&position)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+func (s *SQL) SaveCheckpoint(ctx context.Context, name string, position int64) (err error) {
+	result, err := s.db.ExecContext(ctx, s.query(`UPDATE esui_checkpoints SET position = ? WHERE name = ?`), position, name)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected > 0 {
+		return
+	}
+
+	_, err = s.db.ExecContext(ctx, s.query(`INSERT INTO esui_checkpoints (name, position) VALUES (?, ?)`), name, position)
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package eventstore_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLStoreAndFetch(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "events.db")
+
+	store, err := eventstore.OpenSQL(ctx, openSQLite(t, path))
+	require.NoError(t, err)
+	require.NoError(t, store.StoreEvent(esui.WithActor(ctx, "arief"), "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, esui.AnyVersion))
+	require.NoError(t, store.StoreEvent(ctx, "xyz123", "projection", "created", esui.EsuiProjectionCreated{Name: "projection1"}, esui.AnyVersion))
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, esui.AnyVersion))
+
+	// Opening again must not rerun the migrations.
+	store, err = eventstore.OpenSQL(ctx, openSQLite(t, path))
+	require.NoError(t, err)
+
+	events, err := store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "created", events[0].EventName)
+	assert.Equal(t, `{"name":"user"}`, events[0].Data)
+	assert.EqualValues(t, "3", events[1].EventID)
+	assert.WithinDuration(t, time.Now(), events[0].Timestamp, time.Minute)
+	assert.Equal(t, esui.EventMetadata{ActorID: "arief"}, events[0].Metadata)
+
+	events, err = store.FetchAggregateEvents(ctx, "abc123", "entity", "1")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "event_added", events[0].EventName)
+
+	_, err = store.FetchAggregateEvents(ctx, "abc123", "entity", "2")
+	assert.ErrorIs(t, err, eventstore.ErrEventNotFound)
+}
+
+func TestSQLWithEsui(t *testing.T) {
+	ctx := context.TODO()
+	store, err := eventstore.OpenSQL(ctx, openSQLite(t, filepath.Join(t.TempDir(), "events.db")))
+	require.NoError(t, err)
+	es := esui.NewEsui(store, shortIDGenerator{})
+
+	projectionID, err := es.CreateProjection(ctx, "projection1")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "table1"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "table1", "column1", "string"))
+
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	assert.Equal(t, esui.ColumnString, projection.Tables["table1"].Columns["column1"].Type)
+}
+
+func TestSQLExpectedVersion(t *testing.T) {
+	ctx := context.TODO()
+	store, err := eventstore.OpenSQL(ctx, openSQLite(t, filepath.Join(t.TempDir(), "events.db")))
+	require.NoError(t, err)
+
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "xyz123", "projection", "created", esui.EsuiProjectionCreated{Name: "projection1"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, 1))
+
+	err = store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, 1)
+	assert.ErrorIs(t, err, esui.ErrConcurrency)
+}
+
+func TestSQLFetchAllEventsAndCheckpoints(t *testing.T) {
+	ctx := context.TODO()
+	store, err := eventstore.OpenSQL(ctx, openSQLite(t, filepath.Join(t.TempDir(), "events.db")))
+	require.NoError(t, err)
+
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "created", esui.EsuiEntityCreated{Name: "user"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "xyz123", "projection", "created", esui.EsuiProjectionCreated{Name: "projection1"}, 0))
+	require.NoError(t, store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: "user_created"}, 1))
+
+	events, err := store.FetchAllEvents(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.EqualValues(t, 2, events[0].Position)
+
+	position, err := store.LoadCheckpoint(ctx, "codegen")
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, position)
+	require.NoError(t, store.SaveCheckpoint(ctx, "codegen", 2))
+	require.NoError(t, store.SaveCheckpoint(ctx, "codegen", 3))
+	position, err = store.LoadCheckpoint(ctx, "codegen")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, position)
+}
+
+func TestSQLConcurrentStore(t *testing.T) {
+	ctx := context.TODO()
+	store, err := eventstore.OpenSQL(ctx, openSQLite(t, filepath.Join(t.TempDir(), "events.db")))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			err := store.StoreEvent(ctx, fmt.Sprint("entity", i), "entity", "created", esui.EsuiEntityCreated{Name: fmt.Sprint("entity", i)}, 0)
+			assert.NoError(t, err)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			err := store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: fmt.Sprint("event", i)}, esui.AnyVersion)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	events, err := store.FetchAllEvents(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, events, 40)
+	for i, event := range events {
+		assert.EqualValues(t, i+1, event.Position)
+	}
+	events, err = store.FetchAggregateEvents(ctx, "abc123", "entity", "")
+	require.NoError(t, err)
+	assert.Len(t, events, 20)
+
+	t.Run("Same Expected Version", func(t *testing.T) {
+		var stored atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := store.StoreEvent(ctx, "abc123", "entity", "event_added", esui.EsuiEventAdded{Name: fmt.Sprint("late", i)}, 20)
+				if err == nil {
+					stored.Add(1)
+					return
+				}
+				assert.ErrorIs(t, err, esui.ErrConcurrency)
+			}(i)
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, stored.Load())
+	})
+}
@@ -0,0 +1,323 @@
+package jsruntime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/logger"
+	"github.com/dop251/goja"
+)
+
+var (
+	ErrTimeout        = errors.New("block exceeded its wall clock limit")
+	ErrChangeSize     = errors.New("block changes grew past their size limit")
+	ErrTooManyRows    = errors.New("block changed too many rows")
+	ErrUnknownTable   = errors.New("unknown table")
+	ErrUnknownField   = errors.New("unknown column")
+	ErrNoPrimaryKey   = errors.New("table has no primary key")
+	ErrDuplicateKey   = errors.New("duplicate primary key")
+	ErrDuplicateIndex = errors.New("duplicate value in unique index")
+)
+
+const BlockTypeJavascript = esui.BlockTypeJavascript
+
+type Operation string
+
+const (
+	Insert Operation = "insert"
+	Update Operation = "update"
+	Delete Operation = "delete"
+	Upsert Operation = "upsert"
+)
+
+// Change is one row operation requested by a block. Insert uses Row, Update
+// sets the columns of Row on every row matching Where and Delete removes
+// every row matching Where. Upsert is Update when a row matches Where, the
+// primary key of Row, and Insert otherwise. A row matches when all Where
+// columns are equal. On a table with a primary key Insert carries the key of
+// Row in Where too, and stores reject it with ErrDuplicateKey when a row
+// already matches. Insert, Update and Upsert carry the columns of every
+// unique index of the table in Unique, by index name, and stores reject them
+// with ErrDuplicateIndex when two rows end up with the same values there.
+type Change struct {
+	Table     string                 `json:"table"`
+	Operation Operation              `json:"operation"`
+	Row       map[string]interface{} `json:"row,omitempty"`
+	Where     map[string]interface{} `json:"where,omitempty"`
+	Unique    map[string][]string    `json:"unique,omitempty"`
+}
+
+// Limits bound a single Execute call. A zero value disables that limit.
+// They are coarse guards against runaway blocks, not per-VM quotas: goja has
+// no accounting of the CPU time or memory of one VM.
+//
+// WallTimeout is elapsed wall clock time, so time spent waiting for the CPU
+// counts too. MaxChangeBytes bounds the JSON encoded size of the rows and
+// where clauses a block hands to the table API, summed over the Execute.
+// Memory a block holds inside the VM is not counted, WallTimeout ends a
+// block that keeps growing it.
+type Limits struct {
+	WallTimeout    time.Duration
+	MaxChangeBytes int
+	MaxChanges     int
+}
+
+// BlockError tells which block failed.
+type BlockError struct {
+	BlockID string
+	Err     error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("block %s: %v", e.BlockID, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+type compiledBlock struct {
+	id      string
+	program *goja.Program
+}
+
+// Runtime runs the javascript blocks of one projection. Every block body is
+// called as a function of (event, table), where table(name) returns an
+// object with insert(row), update(where, values), delete(where) and
+// upsert(row).
+type Runtime struct {
+	tables map[string]esui.EsuiTable
+	blocks []compiledBlock
+	limits Limits
+}
+
+// Compile parses a javascript block without running it.
+func Compile(block esui.Block) (program *goja.Program, err error) {
+	program, err = goja.Compile(block.BlockID, block.Function(), true)
+	if err != nil {
+		err = &BlockError{BlockID: block.BlockID, Err: err}
+	}
+	return
+}
+
+func New(projection esui.EsuiProjection, limits Limits) (obj *Runtime, err error) {
+	blocks, err := projection.OrderedBlocks()
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+
+	obj = &Runtime{
+		tables: projection.Tables,
+		limits: limits,
+	}
+	for _, block := range blocks {
+		if block.Type != BlockTypeJavascript {
+			if block.Data.Javascript == nil {
+				continue
+			}
+			err = &BlockError{BlockID: block.BlockID, Err: esui.ErrBlockType}
+			logger.Println(err)
+			return nil, err
+		}
+		var program *goja.Program
+		program, err = Compile(block)
+		if err != nil {
+			logger.Println(err)
+			return nil, err
+		}
+		obj.blocks = append(obj.blocks, compiledBlock{id: block.BlockID, program: program})
+	}
+	return
+}
+
+// Execute runs every block in order for one event and returns the changes
+// they requested. It is all or nothing: when a block fails the changes of
+// the earlier blocks are dropped too and the error is a *BlockError.
+func (r *Runtime) Execute(ctx context.Context, event esui.EstoreEvent) (changes []Change, err error) {
+	vm := goja.New()
+	vm.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+
+	var data interface{}
+	if event.Data != "" {
+		err = json.Unmarshal([]byte(event.Data), &data)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+	}
+	jsEvent := vm.ToValue(map[string]interface{}{
+		"event_id":       string(event.EventID),
+		"position":       event.Position,
+		"aggregate_id":   string(event.AggregateID),
+		"aggregate_name": event.AggregateName,
+		"event_name":     event.EventName,
+		"data":           data,
+	})
+
+	var limitErr error
+	var limitMu sync.Mutex
+	interrupt := func(reason error) {
+		limitMu.Lock()
+		defer limitMu.Unlock()
+		if limitErr == nil {
+			limitErr = reason
+			vm.Interrupt(reason)
+		}
+	}
+	stop := r.watch(ctx, interrupt)
+	defer stop()
+
+	var size int
+	jsTable := vm.ToValue(func(name string) *goja.Object {
+		return r.tableAPI(vm, name, &changes, &size, interrupt)
+	})
+
+	for _, block := range r.blocks {
+		var fn goja.Value
+		fn, err = vm.RunProgram(block.program)
+		if err == nil {
+			call, _ := goja.AssertFunction(fn)
+			_, err = call(goja.Undefined(), jsEvent, jsTable)
+		}
+		if err != nil {
+			limitMu.Lock()
+			if limitErr != nil {
+				err = limitErr
+			}
+			limitMu.Unlock()
+			err = &BlockError{BlockID: block.id, Err: unwrapException(err)}
+			logger.Println(ctx, err)
+			return nil, err
+		}
+	}
+	return
+}
+
+// unwrapException returns the Go error a script threw through the table API
+// so callers can match it with errors.Is.
+func unwrapException(err error) error {
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		if goErr, ok := exception.Value().Export().(error); ok {
+			return goErr
+		}
+	}
+	return err
+}
+
+// watch interrupts the vm when the context ends or a limit is hit. The
+// returned function stops watching.
+func (r *Runtime) watch(ctx context.Context, interrupt func(error)) (stop func()) {
+	done := make(chan struct{})
+	var timer *time.Timer
+
+	var timeout <-chan time.Time
+	if r.limits.WallTimeout > 0 {
+		timer = time.NewTimer(r.limits.WallTimeout)
+		timeout = timer.C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				interrupt(ctx.Err())
+				return
+			case <-timeout:
+				interrupt(ErrTimeout)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (r *Runtime) tableAPI(vm *goja.Runtime, name string, changes *[]Change, size *int, interrupt func(error)) *goja.Object {
+	table, ok := r.tables[name]
+	if !ok {
+		panic(vm.NewGoError(fmt.Errorf("%w: %s", ErrUnknownTable, name)))
+	}
+
+	record := func(change Change) {
+		for _, columns := range []map[string]interface{}{change.Row, change.Where} {
+			for column := range columns {
+				if _, ok := table.Columns[column]; !ok {
+					panic(vm.NewGoError(fmt.Errorf("%w: %s.%s", ErrUnknownField, name, column)))
+				}
+			}
+		}
+		if r.limits.MaxChanges > 0 && len(*changes) >= r.limits.MaxChanges {
+			interrupt(ErrTooManyRows)
+			panic(vm.NewGoError(ErrTooManyRows))
+		}
+		if r.limits.MaxChangeBytes > 0 {
+			for _, columns := range []map[string]interface{}{change.Row, change.Where} {
+				data, err := json.Marshal(columns)
+				if err != nil {
+					panic(vm.NewGoError(err))
+				}
+				*size += len(data)
+			}
+			if *size > r.limits.MaxChangeBytes {
+				interrupt(ErrChangeSize)
+				panic(vm.NewGoError(ErrChangeSize))
+			}
+		}
+		*changes = append(*changes, change)
+	}
+
+	key := func(row map[string]interface{}) (where map[string]interface{}) {
+		where = make(map[string]interface{})
+		for _, column := range table.PrimaryKey {
+			where[column] = row[column]
+		}
+		return
+	}
+
+	var unique map[string][]string
+	for indexName, index := range table.Indexes {
+		if !index.Unique {
+			continue
+		}
+		if unique == nil {
+			unique = make(map[string][]string)
+		}
+		unique[indexName] = index.Columns
+	}
+
+	api := vm.NewObject()
+	api.Set("insert", func(row map[string]interface{}) {
+		change := Change{Table: name, Operation: Insert, Row: row, Unique: unique}
+		if len(table.PrimaryKey) > 0 {
+			change.Where = key(row)
+		}
+		record(change)
+	})
+	api.Set("update", func(where map[string]interface{}, values map[string]interface{}) {
+		record(Change{Table: name, Operation: Update, Row: values, Where: where, Unique: unique})
+	})
+	api.Set("delete", func(where map[string]interface{}) {
+		record(Change{Table: name, Operation: Delete, Where: where})
+	})
+	api.Set("upsert", func(row map[string]interface{}) {
+		if len(table.PrimaryKey) == 0 {
+			panic(vm.NewGoError(fmt.Errorf("%w: %s", ErrNoPrimaryKey, name)))
+		}
+		record(Change{Table: name, Operation: Upsert, Row: row, Where: key(row), Unique: unique})
+	})
+	return api
+}
@@ -0,0 +1,213 @@
+package jsruntime_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/jsruntime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func javascript(id string, after string, source string) esui.Block {
+	return esui.Block{
+		BlockID:      id,
+		Type:         jsruntime.BlockTypeJavascript,
+		OrderedAfter: after,
+		Data:         esui.BlockData{Javascript: &source},
+	}
+}
+
+func projection(blocks ...esui.Block) esui.EsuiProjection {
+	return esui.EsuiProjection{
+		Name: "product_list",
+		Tables: map[string]esui.EsuiTable{
+			"products": {
+				Name: "products",
+				Columns: map[string]esui.EsuiColumn{
+					"id":    {Name: "id", Type: "string"},
+					"name":  {Name: "name", Type: "string"},
+					"price": {Name: "price", Type: "int"},
+				},
+			},
+		},
+		Blocks: blocks,
+	}
+}
+
+var productCreated = esui.EstoreEvent{
+	EventID:       "1",
+	AggregateID:   "prod1",
+	AggregateName: "product",
+	EventName:     "product_created",
+	Data:          `{"name":"book","price":10}`,
+}
+
+func TestExecute(t *testing.T) {
+	ctx := context.TODO()
+	runtime, err := jsruntime.New(projection(
+		javascript("update", "insert", `
+			if (event.event_name !== "product_created") return;
+			table("products").update({id: event.aggregate_id}, {price: event.data.price * 2});
+		`),
+		javascript("insert", "", `
+			table("products").insert({id: event.aggregate_id, name: event.data.name, price: event.data.price});
+		`),
+		esui.Block{BlockID: "note", Type: "comment", OrderedAfter: "update"},
+	), jsruntime.Limits{WallTimeout: time.Second})
+	require.NoError(t, err)
+
+	changes, err := runtime.Execute(ctx, productCreated)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, jsruntime.Change{
+		Table:     "products",
+		Operation: jsruntime.Insert,
+		Row:       map[string]interface{}{"id": "prod1", "name": "book", "price": int64(10)},
+	}, changes[0])
+	assert.Equal(t, jsruntime.Update, changes[1].Operation)
+	assert.Equal(t, map[string]interface{}{"id": "prod1"}, changes[1].Where)
+	assert.EqualValues(t, 20, changes[1].Row["price"])
+}
+
+func TestExecuteErrors(t *testing.T) {
+	ctx := context.TODO()
+
+	t.Run("Syntax Error", func(t *testing.T) {
+		_, err := jsruntime.New(projection(javascript("broken", "", `table(`)), jsruntime.Limits{})
+		var blockErr *jsruntime.BlockError
+		require.ErrorAs(t, err, &blockErr)
+		assert.Equal(t, "broken", blockErr.BlockID)
+	})
+
+	t.Run("Javascript Under Another Type", func(t *testing.T) {
+		block := javascript("sql", "", `table("products").insert({id: "a"});`)
+		block.Type = "sql"
+		_, err := jsruntime.New(projection(block), jsruntime.Limits{})
+		assert.ErrorIs(t, err, esui.ErrBlockType)
+	})
+
+	t.Run("Failing Block Drops All Changes", func(t *testing.T) {
+		runtime, err := jsruntime.New(projection(
+			javascript("insert", "", `table("products").insert({id: "a"});`),
+			javascript("throw", "insert", `throw new Error("boom");`),
+		), jsruntime.Limits{})
+		require.NoError(t, err)
+		changes, err := runtime.Execute(ctx, productCreated)
+		var blockErr *jsruntime.BlockError
+		require.ErrorAs(t, err, &blockErr)
+		assert.Equal(t, "throw", blockErr.BlockID)
+		assert.Contains(t, err.Error(), "boom")
+		assert.Nil(t, changes)
+	})
+
+	t.Run("Unknown Table And Column", func(t *testing.T) {
+		runtime, err := jsruntime.New(projection(javascript("a", "", `table("orders").insert({id: "a"});`)), jsruntime.Limits{})
+		require.NoError(t, err)
+		_, err = runtime.Execute(ctx, productCreated)
+		assert.ErrorIs(t, err, jsruntime.ErrUnknownTable)
+
+		runtime, err = jsruntime.New(projection(javascript("a", "", `table("products").insert({color: "red"});`)), jsruntime.Limits{})
+		require.NoError(t, err)
+		_, err = runtime.Execute(ctx, productCreated)
+		assert.ErrorIs(t, err, jsruntime.ErrUnknownField)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		runtime, err := jsruntime.New(projection(javascript("loop", "", `while (true) {}`)), jsruntime.Limits{WallTimeout: 50 * time.Millisecond})
+		require.NoError(t, err)
+		_, err = runtime.Execute(ctx, productCreated)
+		assert.ErrorIs(t, err, jsruntime.ErrTimeout)
+	})
+
+	t.Run("Change Size", func(t *testing.T) {
+		runtime, err := jsruntime.New(projection(javascript("grow", "", `
+			for (var i = 0; ; i++) { table("products").insert({id: "p" + i, name: new Array(1024).fill("x").join("")}); }
+		`)), jsruntime.Limits{WallTimeout: 10 * time.Second, MaxChangeBytes: 1 << 20})
+		require.NoError(t, err)
+		_, err = runtime.Execute(ctx, productCreated)
+		assert.ErrorIs(t, err, jsruntime.ErrChangeSize)
+	})
+
+	t.Run("Too Many Changes", func(t *testing.T) {
+		runtime, err := jsruntime.New(projection(javascript("many", "", `
+			for (var i = 0; i < 10; i++) { table("products").delete({id: "x"}); }
+		`)), jsruntime.Limits{MaxChanges: 3})
+		require.NoError(t, err)
+		_, err = runtime.Execute(ctx, productCreated)
+		assert.ErrorIs(t, err, jsruntime.ErrTooManyRows)
+	})
+
+	t.Run("Context Canceled", func(t *testing.T) {
+		runtime, err := jsruntime.New(projection(javascript("loop", "", `while (true) {}`)), jsruntime.Limits{})
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err = runtime.Execute(ctx, productCreated)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestExecuteUpsert(t *testing.T) {
+	ctx := context.TODO()
+	block := javascript("upsert", "", `table("products").upsert({id: event.aggregate_id, name: event.data.name});`)
+
+	runtime, err := jsruntime.New(projection(block), jsruntime.Limits{})
+	require.NoError(t, err)
+	_, err = runtime.Execute(ctx, productCreated)
+	assert.ErrorIs(t, err, jsruntime.ErrNoPrimaryKey)
+
+	keyed := projection(block)
+	products := keyed.Tables["products"]
+	products.PrimaryKey = []string{"id"}
+	keyed.Tables["products"] = products
+	runtime, err = jsruntime.New(keyed, jsruntime.Limits{})
+	require.NoError(t, err)
+	changes, err := runtime.Execute(ctx, productCreated)
+	require.NoError(t, err)
+	assert.Equal(t, []jsruntime.Change{{
+		Table:     "products",
+		Operation: jsruntime.Upsert,
+		Row:       map[string]interface{}{"id": "prod1", "name": "book"},
+		Where:     map[string]interface{}{"id": "prod1"},
+	}}, changes)
+}
+
+func TestExecuteInsertKey(t *testing.T) {
+	ctx := context.TODO()
+	keyed := projection(javascript("insert", "", `table("products").insert({id: event.aggregate_id, name: event.data.name});`))
+	products := keyed.Tables["products"]
+	products.PrimaryKey = []string{"id"}
+	keyed.Tables["products"] = products
+
+	runtime, err := jsruntime.New(keyed, jsruntime.Limits{})
+	require.NoError(t, err)
+	changes, err := runtime.Execute(ctx, productCreated)
+	require.NoError(t, err)
+	assert.Equal(t, []jsruntime.Change{{
+		Table:     "products",
+		Operation: jsruntime.Insert,
+		Row:       map[string]interface{}{"id": "prod1", "name": "book"},
+		Where:     map[string]interface{}{"id": "prod1"},
+	}}, changes)
+}
+
+func TestExecuteUniqueIndex(t *testing.T) {
+	ctx := context.TODO()
+	indexed := projection(javascript("insert", "", `table("products").insert({id: event.aggregate_id, name: event.data.name});`))
+	products := indexed.Tables["products"]
+	products.Indexes = map[string]esui.EsuiIndex{
+		"by_name":  {Name: "by_name", Columns: []string{"name"}, Unique: true},
+		"by_price": {Name: "by_price", Columns: []string{"price"}},
+	}
+	indexed.Tables["products"] = products
+
+	runtime, err := jsruntime.New(indexed, jsruntime.Limits{})
+	require.NoError(t, err)
+	changes, err := runtime.Execute(ctx, productCreated)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, map[string][]string{"by_name": {"name"}}, changes[0].Unique)
+}
@@ -0,0 +1,53 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventMetadata(t *testing.T) {
+	ctx := esui.WithCorrelationID(esui.WithActor(context.TODO(), "arief"), "request1")
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+
+	events, err := estore.FetchAggregateEvents(ctx, string(entityID), "entity", "")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, esui.EventMetadata{ActorID: "arief", CorrelationID: "request1"}, events[0].Metadata)
+
+	t.Run("Handlers Store Caused Events", func(t *testing.T) {
+		handler := func(ctx context.Context, event esui.EstoreEvent) error {
+			if event.AggregateName != "entity" || event.EventName != "created" {
+				return nil
+			}
+			return es.AddEventToEntity(ctx, event.AggregateID, "product_created")
+		}
+		subscription := esui.NewSubscription("handler", estore, eventstore.NewMemoryCheckpoints(), handler)
+		require.NoError(t, subscription.CatchUp(esui.WithActor(context.TODO(), "worker")))
+
+		events, err := estore.FetchAggregateEvents(ctx, string(entityID), "entity", "")
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, esui.EventMetadata{
+			ActorID:       "worker",
+			CorrelationID: "request1",
+			CausationID:   string(events[0].EventID),
+		}, events[1].Metadata)
+	})
+
+	t.Run("Root Event Starts Correlation", func(t *testing.T) {
+		event := esui.EstoreEvent{EventID: "42"}
+		metadata := esui.MetadataFromContext(esui.CausedBy(context.TODO(), event))
+		assert.Equal(t, esui.EventMetadata{CorrelationID: "42", CausationID: "42"}, metadata)
+	})
+}
@@ -0,0 +1,71 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectionSubscriptions(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_deleted"))
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+
+	require.NoError(t, es.SubscribeProjection(ctx, projectionID, entityID, "product_created"))
+	require.NoError(t, es.SubscribeProjection(ctx, projectionID, entityID, "product_deleted"))
+
+	t.Run("Validation", func(t *testing.T) {
+		assert.ErrorIs(t, es.SubscribeProjection(ctx, projectionID, entityID, "product_created"), esui.ErrAlreadySubscribed)
+		assert.ErrorIs(t, es.SubscribeProjection(ctx, projectionID, entityID, "product_updated"), esui.ErrEventNotFound)
+		assert.ErrorIs(t, es.SubscribeProjection(ctx, projectionID, "unknown", "product_created"), esui.ErrEntityNotFound)
+		assert.ErrorIs(t, es.SubscribeProjection(ctx, "unknown", entityID, "product_created"), esui.ErrProjectionNotFound)
+		assert.ErrorIs(t, es.UnsubscribeProjection(ctx, projectionID, entityID, "product_updated"), esui.ErrNotSubscribed)
+	})
+
+	t.Run("Event In Use Cannot Be Removed", func(t *testing.T) {
+		assert.ErrorIs(t, es.RemoveEventFromEntity(ctx, entityID, "product_deleted"), esui.ErrEventInUse)
+		assert.ErrorIs(t, es.RenameEvent(ctx, entityID, "product_deleted", "product_removed"), esui.ErrEventInUse)
+		assert.ErrorIs(t, es.ArchiveEntity(ctx, entityID), esui.ErrEventInUse)
+		assert.ErrorIs(t, es.RenameEntity(ctx, entityID, "item"), esui.ErrEventInUse)
+		require.NoError(t, es.UnsubscribeProjection(ctx, projectionID, entityID, "product_deleted"))
+		require.NoError(t, es.RemoveEventFromEntity(ctx, entityID, "product_deleted"))
+	})
+
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	assert.Equal(t, map[esui.ShortID]map[string]bool{
+		entityID: {"product_created": true},
+	}, projection.Subscriptions)
+}
+
+func TestSubscribersReadEventStream(t *testing.T) {
+	ctx := context.TODO()
+	estore := &mockEventstore{}
+	es := esui.NewEsui(estore, &mockIDGenerator{})
+
+	estore.On("FetchAggregateEvents", "prod1", "entity", "").Return([]esui.EstoreEvent{
+		{EventID: "1", AggregateID: "prod1", AggregateName: "entity", EventName: "created", Data: `{"name":"product"}`},
+		{EventID: "2", AggregateID: "prod1", AggregateName: "entity", EventName: "event_added", Data: `{"name":"product_deleted"}`},
+	}, nil)
+	estore.On("FetchAllEvents", int64(0), 500).Return([]esui.EstoreEvent{
+		{EventID: "3", Position: 3, AggregateID: "proj1", AggregateName: "projection", EventName: "created", Data: `{"name":"product_list"}`},
+		{EventID: "4", Position: 4, AggregateID: "proj1", AggregateName: "projection", EventName: "subscribed", Data: `{"entity_id":"prod1","event_name":"product_deleted"}`},
+	}, nil)
+
+	err := es.RemoveEventFromEntity(ctx, "prod1", "product_deleted")
+	assert.ErrorIs(t, err, esui.ErrEventInUse)
+	estore.AssertNotCalled(t, "StoreEvent")
+}
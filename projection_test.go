@@ -18,14 +18,14 @@ func TestProjection(t *testing.T) {
 	idgenerator.On("Generate").Return("xyz123").Once()
 	estore.On("StoreEvent", "xyz123", "projection", "created", esui.EsuiProjectionCreated{
 		Name: "projection1",
-	}).Return(nil).Once()
+	}, int64(0)).Return(nil).Once()
 	projID, err := es.CreateProjection(ctx, "projection1")
 	assert.NoError(t, err)
 	assert.EqualValues(t, "xyz123", projID)
 
 	estore.AssertCalled(t, "StoreEvent", "xyz123", "projection", "created", esui.EsuiProjectionCreated{
 		Name: "projection1",
-	})
+	}, int64(0))
 
 }
 
@@ -79,14 +79,14 @@ func TestCreateTable(t *testing.T) {
 
 		estore.On("StoreEvent", "proj1", "projection", "table_created", esui.EsuiTableCreated{
 			Name: "table1",
-		}).Return(nil).Once()
+		}, int64(1)).Return(nil).Once()
 
 		err := es.CreateTable(ctx, "proj1", "table1")
 		assert.NoError(t, err)
 
 		estore.AssertCalled(t, "StoreEvent", "proj1", "projection", "table_created", esui.EsuiTableCreated{
 			Name: "table1",
-		})
+		}, int64(1))
 	})
 
 	t.Run("Get Projection With Table", func(t *testing.T) {
@@ -154,7 +154,7 @@ func TestAddColumn(t *testing.T) {
 			TableName:  "table1",
 			ColumnName: "column1",
 			ColumnType: "string",
-		}).Return(nil).Once()
+		}, int64(2)).Return(nil).Once()
 
 		err := es.AddColumn(ctx, "proj1", "table1", "column1", "string")
 		assert.NoError(t, err)
@@ -163,7 +163,7 @@ func TestAddColumn(t *testing.T) {
 			TableName:  "table1",
 			ColumnName: "column1",
 			ColumnType: "string",
-		})
+		}, int64(2))
 	})
 
 	t.Run("Add Column Unknown Table", func(t *testing.T) {
@@ -242,11 +242,11 @@ func TestAddBlockJavascriptToProjection(t *testing.T) {
 		},
 	}, nil).Once()
 
-	estore.On("StoreEvent", "proj1", "projection", "block_added", data).Return(nil).Once()
+	estore.On("StoreEvent", "proj1", "projection", "block_added", data, int64(1)).Return(nil).Once()
 
 	err := es.AddBlock(ctx, "proj1", data)
 	assert.NoError(t, err)
 
-	estore.AssertCalled(t, "StoreEvent", "proj1", "projection", "block_added", data)
+	estore.AssertCalled(t, "StoreEvent", "proj1", "projection", "block_added", data, int64(1))
 
 }
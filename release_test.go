@@ -0,0 +1,116 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishApplicationVersion(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	idgenerator.On("Generate").Return("app1").Once()
+	applicationID, err := es.CreateApplication(ctx, "shop")
+	require.NoError(t, err)
+	require.NoError(t, es.AttachEntity(ctx, applicationID, entityID))
+
+	first, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+	again, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	require.NoError(t, es.AddAttribute(ctx, entityID, "product_created", "name", esui.TypeString))
+	second, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	t.Run("Release Is Frozen", func(t *testing.T) {
+		release, err := es.GetApplicationVersion(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, first, release.Version)
+		assert.Empty(t, release.Entity["prod1"].Events["product_created"].Attribute)
+
+		release, err = es.GetApplicationVersion(ctx, second)
+		require.NoError(t, err)
+		assert.Equal(t, esui.TypeString, release.Entity["prod1"].Events["product_created"].Attribute["name"])
+
+		current, err := es.GetApplication(ctx, applicationID)
+		require.NoError(t, err)
+		assert.Equal(t, second, current.Version)
+	})
+
+	t.Run("List Releases", func(t *testing.T) {
+		versions, err := es.ListApplicationVersions(ctx, applicationID)
+		require.NoError(t, err)
+		assert.Equal(t, []esui.ShortID{first, second}, versions)
+
+		_, err = es.ListApplicationVersions(ctx, "unknown")
+		assert.ErrorIs(t, err, esui.ErrApplicationNotFound)
+		_, err = es.GetApplicationVersion(ctx, "unknown")
+		assert.ErrorIs(t, err, esui.ErrVersionNotFound)
+	})
+}
+
+func TestPublishFullDefinitions(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("order1").Once()
+	entityID, err := es.CreateEntity(ctx, "order")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "order_placed"))
+	require.NoError(t, es.AddAttributeSchema(ctx, entityID, "order_placed", "status", esui.AttributeSchema{
+		Type:   esui.TypeEnum,
+		Values: []string{"new", "paid", "shipped"},
+	}))
+	idgenerator.On("Generate").Return("app1").Once()
+	applicationID, err := es.CreateApplication(ctx, "shop")
+	require.NoError(t, err)
+	require.NoError(t, es.AttachEntity(ctx, applicationID, entityID))
+
+	first, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+	require.NoError(t, es.ChangeAttributeSchema(ctx, entityID, "order_placed", "status", esui.AttributeSchema{
+		Type:   esui.TypeEnum,
+		Values: []string{"new", "paid"},
+	}))
+	second, err := es.PublishApplicationVersion(ctx, applicationID)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	release, err := es.GetRelease(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, first, release.Application.Version)
+	assert.Equal(t, []string{"new", "paid", "shipped"}, release.Entities[entityID].Events["order_placed"].Schemas["status"].Values)
+	assert.Zero(t, release.Entities[entityID].Version)
+
+	t.Run("Blocks Hashed In Run Order", func(t *testing.T) {
+		idgenerator.On("Generate").Return("proj1").Once()
+		projectionID, err := es.CreateProjection(ctx, "order_list")
+		require.NoError(t, err)
+		script := "return;"
+		require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "a", Type: esui.BlockTypeJavascript, Data: esui.BlockData{Javascript: &script}}))
+		require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "b", Type: esui.BlockTypeJavascript, OrderedAfter: "a", Data: esui.BlockData{Javascript: &script}}))
+		require.NoError(t, es.AttachProjection(ctx, applicationID, projectionID))
+		before, err := es.PublishApplicationVersion(ctx, applicationID)
+		require.NoError(t, err)
+
+		require.NoError(t, es.RemoveBlock(ctx, projectionID, "a"))
+		require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{BlockID: "a", Type: esui.BlockTypeJavascript, Data: esui.BlockData{Javascript: &script}}))
+		after, err := es.PublishApplicationVersion(ctx, applicationID)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+}
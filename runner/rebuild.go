@@ -0,0 +1,103 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/jsruntime"
+	"github.com/ariefsam/esui/logger"
+)
+
+const rebuildBatchSize = 100
+
+// Progress describes how far a rebuild got. Position is the last event read
+// from the stream, Processed how many of the events read the projection
+// consumed.
+type Progress struct {
+	Position  int64
+	Processed int
+}
+
+type ProgressFunc func(Progress)
+
+// WithProgress makes Rebuild report its progress to fn after every batch of
+// events.
+func WithProgress(fn ProgressFunc) Option {
+	return func(r *Runner) {
+		r.progress = fn
+	}
+}
+
+// Rebuild materializes the tables again from the first event with the
+// current blocks. The rows are built aside and swapped in once the stream is
+// exhausted, so readers keep seeing the old rows until then and a failed
+// rebuild leaves them untouched. The checkpoint is moved to the end of the
+// rebuild right after the swap. Rebuild must not run while the same
+// projection is running.
+func (r *Runner) Rebuild(ctx context.Context) (err error) {
+	shadow := r.Name() + "/rebuild"
+	err = r.store.Drop(ctx, shadow)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	progress := Progress{}
+	for {
+		var events []esui.EstoreEvent
+		events, err = r.stream.FetchAllEvents(ctx, progress.Position, rebuildBatchSize)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		for _, event := range events {
+			if r.events[event.AggregateName][event.EventName] {
+				progress.Processed++
+			}
+			err = r.handle(ctx, shadow, event)
+			if err != nil {
+				logger.Println(ctx, err)
+				return
+			}
+			progress.Position = event.Position
+		}
+		if r.progress != nil {
+			r.progress(progress)
+		}
+	}
+
+	err = r.store.Swap(ctx, r.Name(), shadow)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	err = r.checkpoints.SaveCheckpoint(ctx, r.Name(), progress.Position)
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// RebuildProjection loads the active projection like Load does and rebuilds
+// its tables.
+func RebuildProjection(
+	ctx context.Context,
+	es *esui.Esui,
+	projectionID esui.ShortID,
+	stream esui.EventStream,
+	checkpoints esui.CheckpointStore,
+	store Store,
+	limits jsruntime.Limits,
+	options ...Option,
+) (err error) {
+	r, err := Load(ctx, es, projectionID, stream, checkpoints, store, limits, options...)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	return r.Rebuild(ctx)
+}
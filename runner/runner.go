@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/jsruntime"
+	"github.com/ariefsam/esui/logger"
+)
+
+// Runner materializes the tables of one projection. It follows the event
+// stream with a checkpoint named after the projection, runs the projection's
+// blocks for every event it consumes and applies their changes to a Store.
+// Delivery is at least once: an event applied right before a crash is
+// applied again on restart.
+type Runner struct {
+	projection   esui.EsuiProjection
+	runtime      *jsruntime.Runtime
+	stream       esui.EventStream
+	checkpoints  esui.CheckpointStore
+	store        Store
+	subscription *esui.Subscription
+	events       map[string]map[string]bool
+	progress     ProgressFunc
+}
+
+type Option func(*Runner)
+
+// WithEvents makes the runner consume eventNames of the entity named
+// entityName, every other event is skipped. Domain events carry the entity
+// name rather than its ID, esui keeps entity names unique and refuses to
+// rename an entity while a projection consumes its events.
+func WithEvents(entityName string, eventNames ...string) Option {
+	return func(r *Runner) {
+		if r.events[entityName] == nil {
+			r.events[entityName] = make(map[string]bool)
+		}
+		for _, eventName := range eventNames {
+			r.events[entityName][eventName] = true
+		}
+	}
+}
+
+func New(
+	projection esui.EsuiProjection,
+	stream esui.EventStream,
+	checkpoints esui.CheckpointStore,
+	store Store,
+	limits jsruntime.Limits,
+	options ...Option,
+) (obj *Runner, err error) {
+	runtime, err := jsruntime.New(projection, limits)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+
+	obj = &Runner{
+		projection:  projection,
+		runtime:     runtime,
+		stream:      stream,
+		checkpoints: checkpoints,
+		store:       store,
+		events:      make(map[string]map[string]bool),
+	}
+	for _, option := range options {
+		option(obj)
+	}
+	obj.subscription = esui.NewSubscription(obj.Name(), stream, checkpoints, obj.Handle)
+	return
+}
+
+// Load builds the runner of an active projection that is not archived. The
+// events it consumes are the projection's subscriptions, resolved to entity
+// names through es, options are applied after them.
+func Load(
+	ctx context.Context,
+	es *esui.Esui,
+	projectionID esui.ShortID,
+	stream esui.EventStream,
+	checkpoints esui.CheckpointStore,
+	store Store,
+	limits jsruntime.Limits,
+	options ...Option,
+) (obj *Runner, err error) {
+	projection, err := es.GetProjection(ctx, projectionID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+	if projection.Name == "" {
+		err = esui.ErrProjectionNotFound
+		logger.Println(ctx, err)
+		return
+	}
+	if projection.Archived {
+		err = esui.ErrArchived
+		logger.Println(ctx, err)
+		return
+	}
+	if !projection.IsActive {
+		err = esui.ErrNotActive
+		logger.Println(ctx, err)
+		return
+	}
+
+	var subscriptions []Option
+	for entityID, eventNames := range projection.Subscriptions {
+		var entity esui.EsuiEntity
+		entity, err = es.GetEntity(ctx, entityID)
+		if err != nil {
+			logger.Println(ctx, err)
+			return
+		}
+		for eventName := range eventNames {
+			subscriptions = append(subscriptions, WithEvents(entity.Name, eventName))
+		}
+	}
+
+	return New(projection, stream, checkpoints, store, limits, append(subscriptions, options...)...)
+}
+
+// Name is the checkpoint and Store name of the projection.
+func (r *Runner) Name() string {
+	return "projection/" + string(r.projection.ID)
+}
+
+func (r *Runner) Handle(ctx context.Context, event esui.EstoreEvent) (err error) {
+	return r.handle(ctx, r.Name(), event)
+}
+
+// handle applies the changes of a consumed event to the tables stored under
+// name.
+func (r *Runner) handle(ctx context.Context, name string, event esui.EstoreEvent) (err error) {
+	if !r.events[event.AggregateName][event.EventName] {
+		return
+	}
+
+	changes, err := r.runtime.Execute(ctx, event)
+	if err != nil {
+		logger.Println(ctx, err)
+		return
+	}
+
+	err = r.store.Apply(ctx, name, changes)
+	if err != nil {
+		logger.Println(ctx, err)
+	}
+	return
+}
+
+// CatchUp processes every event after the checkpoint and returns.
+func (r *Runner) CatchUp(ctx context.Context) error {
+	return r.subscription.CatchUp(ctx)
+}
+
+// Run processes events until ctx is done or a block fails.
+func (r *Runner) Run(ctx context.Context) error {
+	return r.subscription.Run(ctx)
+}
+
+func (r *Runner) Rows(ctx context.Context, table string) (rows []Row, err error) {
+	return r.store.Rows(ctx, r.Name(), table)
+}
@@ -0,0 +1,275 @@
+package runner_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/ariefsam/esui/idgenerator"
+	"github.com/ariefsam/esui/jsruntime"
+	"github.com/ariefsam/esui/runner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type shortIDGenerator struct{}
+
+func (shortIDGenerator) Generate() string {
+	return idgenerator.Generate()
+}
+
+const productBlock = `
+switch (event.event_name) {
+case "product_created":
+	table("products").insert({id: event.aggregate_id, name: event.data.name, price: event.data.price});
+	break;
+case "price_changed":
+	table("products").update({id: event.aggregate_id}, {price: event.data.price});
+	break;
+case "product_deleted":
+	table("products").delete({id: event.aggregate_id});
+	break;
+}
+`
+
+// designProductList designs a product list projection and returns it.
+func designProductList(t *testing.T, ctx context.Context, es *esui.Esui) esui.EsuiProjection {
+	projectionID, err := es.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "products"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "products", "id", "string"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "products", "name", "string"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "products", "price", "int"))
+	script := productBlock
+	require.NoError(t, es.AddBlock(ctx, projectionID, esui.Block{
+		BlockID: "products",
+		Type:    jsruntime.BlockTypeJavascript,
+		Data:    esui.BlockData{Javascript: &script},
+	}))
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	return projection
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.TODO()
+	es := esui.NewEsui(eventstore.NewMemory(), shortIDGenerator{})
+	projection := designProductList(t, ctx, es)
+
+	domain := eventstore.NewMemory()
+	checkpoints := eventstore.NewMemoryCheckpoints()
+	store := runner.NewMemoryStore()
+	newRunner := func() *runner.Runner {
+		r, err := runner.New(projection, domain, checkpoints, store, jsruntime.Limits{WallTimeout: time.Second},
+			runner.WithEvents("product", "product_created", "price_changed", "product_deleted"))
+		require.NoError(t, err)
+		return r
+	}
+
+	require.NoError(t, domain.StoreEvent(ctx, "p1", "product", "product_created", map[string]interface{}{"name": "book", "price": 10}, esui.AnyVersion))
+	require.NoError(t, domain.StoreEvent(ctx, "p2", "product", "product_created", map[string]interface{}{"name": "pen", "price": 2}, esui.AnyVersion))
+	require.NoError(t, domain.StoreEvent(ctx, "u1", "user", "user_created", map[string]interface{}{"name": "arief"}, esui.AnyVersion))
+	require.NoError(t, domain.StoreEvent(ctx, "p1", "product", "price_changed", map[string]interface{}{"price": 12}, esui.AnyVersion))
+
+	r := newRunner()
+	require.NoError(t, r.CatchUp(ctx))
+	rows, err := r.Rows(ctx, "products")
+	require.NoError(t, err)
+	assert.Equal(t, []runner.Row{
+		{"id": "p1", "name": "book", "price": int64(12)},
+		{"id": "p2", "name": "pen", "price": int64(2)},
+	}, rows)
+
+	t.Run("Resume After Restart", func(t *testing.T) {
+		require.NoError(t, domain.StoreEvent(ctx, "p2", "product", "product_deleted", map[string]interface{}{}, esui.AnyVersion))
+		r := newRunner()
+		require.NoError(t, r.CatchUp(ctx))
+		rows, err := r.Rows(ctx, "products")
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, "p1", rows[0]["id"])
+	})
+
+	t.Run("Block Error Stops At Event", func(t *testing.T) {
+		require.NoError(t, domain.StoreEvent(ctx, "p3", "product", "product_created", map[string]interface{}{"name": "cup", "price": 3}, esui.AnyVersion))
+		position, err := checkpoints.LoadCheckpoint(ctx, r.Name())
+		require.NoError(t, err)
+
+		broken := projection
+		script := `table("missing").insert({});`
+		broken.Blocks = []esui.Block{{BlockID: "broken", Type: jsruntime.BlockTypeJavascript, Data: esui.BlockData{Javascript: &script}}}
+		r, err := runner.New(broken, domain, checkpoints, store, jsruntime.Limits{}, runner.WithEvents("product", "product_created"))
+		require.NoError(t, err)
+		assert.ErrorIs(t, r.CatchUp(ctx), jsruntime.ErrUnknownTable)
+
+		after, err := checkpoints.LoadCheckpoint(ctx, r.Name())
+		require.NoError(t, err)
+		assert.Equal(t, position, after)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	ctx := context.TODO()
+	es := esui.NewEsui(eventstore.NewMemory(), shortIDGenerator{})
+	projection := designProductList(t, ctx, es)
+
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "price_changed"))
+	require.NoError(t, es.SubscribeProjection(ctx, projection.ID, entityID, "product_created"))
+
+	domain := eventstore.NewMemory()
+	require.NoError(t, domain.StoreEvent(ctx, "p1", "product", "product_created", map[string]interface{}{"name": "book", "price": 10}, esui.AnyVersion))
+	require.NoError(t, domain.StoreEvent(ctx, "p1", "product", "price_changed", map[string]interface{}{"price": 12}, esui.AnyVersion))
+
+	_, err = runner.Load(ctx, es, projection.ID, domain, eventstore.NewMemoryCheckpoints(), runner.NewMemoryStore(), jsruntime.Limits{})
+	assert.ErrorIs(t, err, esui.ErrNotActive)
+	require.NoError(t, es.ActivateProjection(ctx, projection.ID))
+
+	r, err := runner.Load(ctx, es, projection.ID, domain, eventstore.NewMemoryCheckpoints(), runner.NewMemoryStore(), jsruntime.Limits{})
+	require.NoError(t, err)
+	require.NoError(t, r.CatchUp(ctx))
+
+	rows, err := r.Rows(ctx, "products")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.EqualValues(t, 10, rows[0]["price"])
+
+	require.NoError(t, es.ArchiveProjection(ctx, projection.ID))
+	_, err = runner.Load(ctx, es, projection.ID, domain, eventstore.NewMemoryCheckpoints(), runner.NewMemoryStore(), jsruntime.Limits{})
+	assert.ErrorIs(t, err, esui.ErrArchived)
+}
+
+func TestRebuildProjection(t *testing.T) {
+	ctx := context.TODO()
+	es := esui.NewEsui(eventstore.NewMemory(), shortIDGenerator{})
+	projection := designProductList(t, ctx, es)
+
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	require.NoError(t, es.SubscribeProjection(ctx, projection.ID, entityID, "product_created"))
+	require.NoError(t, es.ActivateProjection(ctx, projection.ID))
+
+	domain := eventstore.NewMemory()
+	for i := 0; i < 150; i++ {
+		require.NoError(t, domain.StoreEvent(ctx, idgenerator.Generate(), "product", "product_created", map[string]interface{}{"name": "book", "price": 10}, esui.AnyVersion))
+	}
+	require.NoError(t, domain.StoreEvent(ctx, "u1", "user", "user_created", map[string]interface{}{"name": "arief"}, esui.AnyVersion))
+
+	checkpoints := eventstore.NewMemoryCheckpoints()
+	store := runner.NewMemoryStore()
+	r, err := runner.Load(ctx, es, projection.ID, domain, checkpoints, store, jsruntime.Limits{})
+	require.NoError(t, err)
+	require.NoError(t, r.CatchUp(ctx))
+
+	script := `if (event.event_name === "product_created") {
+	table("products").insert({id: event.aggregate_id, name: event.data.name, price: event.data.price * 2});
+}`
+	block := esui.Block{
+		BlockID: "products",
+		Type:    jsruntime.BlockTypeJavascript,
+		Data:    esui.BlockData{Javascript: &script},
+	}
+	assert.ErrorIs(t, es.UpdateBlock(ctx, projection.ID, block), esui.ErrProjectionActive)
+	require.NoError(t, es.DeactivateProjection(ctx, projection.ID))
+	require.NoError(t, es.UpdateBlock(ctx, projection.ID, block))
+	require.NoError(t, es.ActivateProjection(ctx, projection.ID))
+
+	t.Run("Failed Rebuild Keeps Old Rows", func(t *testing.T) {
+		broken := projection
+		script := `table("missing").insert({});`
+		broken.Blocks = []esui.Block{{BlockID: "broken", Type: jsruntime.BlockTypeJavascript, Data: esui.BlockData{Javascript: &script}}}
+		r, err := runner.New(broken, domain, checkpoints, store, jsruntime.Limits{}, runner.WithEvents("product", "product_created"))
+		require.NoError(t, err)
+		assert.ErrorIs(t, r.Rebuild(ctx), jsruntime.ErrUnknownTable)
+
+		rows, err := r.Rows(ctx, "products")
+		require.NoError(t, err)
+		require.Len(t, rows, 150)
+		assert.EqualValues(t, 10, rows[0]["price"])
+	})
+
+	var reports []runner.Progress
+	err = runner.RebuildProjection(ctx, es, projection.ID, domain, checkpoints, store, jsruntime.Limits{},
+		runner.WithProgress(func(progress runner.Progress) {
+			reports = append(reports, progress)
+		}))
+	require.NoError(t, err)
+	assert.Equal(t, []runner.Progress{
+		{Position: 100, Processed: 100},
+		{Position: 151, Processed: 150},
+	}, reports)
+
+	rows, err := r.Rows(ctx, "products")
+	require.NoError(t, err)
+	require.Len(t, rows, 150)
+	assert.EqualValues(t, 20, rows[0]["price"])
+
+	position, err := checkpoints.LoadCheckpoint(ctx, r.Name())
+	require.NoError(t, err)
+	assert.EqualValues(t, 151, position)
+}
+
+func TestMemoryStoreDuplicateKey(t *testing.T) {
+	ctx := context.TODO()
+	store := runner.NewMemoryStore()
+	insert := func(id string, name string) jsruntime.Change {
+		return jsruntime.Change{
+			Table:     "products",
+			Operation: jsruntime.Insert,
+			Row:       map[string]interface{}{"id": id, "name": name},
+			Where:     map[string]interface{}{"id": id},
+		}
+	}
+
+	require.NoError(t, store.Apply(ctx, "list", []jsruntime.Change{insert("p1", "book")}))
+	err := store.Apply(ctx, "list", []jsruntime.Change{insert("p2", "pen"), insert("p1", "book again")})
+	assert.ErrorIs(t, err, jsruntime.ErrDuplicateKey)
+
+	rows, err := store.Rows(ctx, "list", "products")
+	require.NoError(t, err)
+	assert.Equal(t, []runner.Row{{"id": "p1", "name": "book"}}, rows)
+}
+
+func TestMemoryStoreUniqueIndex(t *testing.T) {
+	ctx := context.TODO()
+	store := runner.NewMemoryStore()
+	unique := map[string][]string{"by_sku": {"sku"}}
+	insert := func(id string, sku interface{}) jsruntime.Change {
+		return jsruntime.Change{
+			Table:     "products",
+			Operation: jsruntime.Insert,
+			Row:       map[string]interface{}{"id": id, "sku": sku},
+			Unique:    unique,
+		}
+	}
+
+	require.NoError(t, store.Apply(ctx, "list", []jsruntime.Change{insert("p1", "a"), insert("p2", nil), insert("p3", nil)}))
+	assert.ErrorIs(t, store.Apply(ctx, "list", []jsruntime.Change{insert("p4", "a")}), jsruntime.ErrDuplicateIndex)
+
+	update := jsruntime.Change{
+		Table:     "products",
+		Operation: jsruntime.Update,
+		Row:       map[string]interface{}{"sku": "a"},
+		Where:     map[string]interface{}{"id": "p2"},
+		Unique:    unique,
+	}
+	assert.ErrorIs(t, store.Apply(ctx, "list", []jsruntime.Change{update}), jsruntime.ErrDuplicateIndex)
+
+	upsert := jsruntime.Change{
+		Table:     "products",
+		Operation: jsruntime.Upsert,
+		Row:       map[string]interface{}{"id": "p5", "sku": "a"},
+		Where:     map[string]interface{}{"id": "p5"},
+		Unique:    unique,
+	}
+	assert.ErrorIs(t, store.Apply(ctx, "list", []jsruntime.Change{upsert}), jsruntime.ErrDuplicateIndex)
+
+	rows, err := store.Rows(ctx, "list", "products")
+	require.NoError(t, err)
+	assert.Len(t, rows, 3)
+}
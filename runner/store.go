@@ -0,0 +1,217 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ariefsam/esui/jsruntime"
+)
+
+type Row map[string]interface{}
+
+// Store keeps the materialized rows of projections. name identifies one
+// projection's set of tables.
+type Store interface {
+	Apply(ctx context.Context, name string, changes []jsruntime.Change) (err error)
+	Rows(ctx context.Context, name string, table string) (rows []Row, err error)
+	// Drop deletes every table stored under name.
+	Drop(ctx context.Context, name string) (err error)
+	// Swap atomically replaces the tables of name with the tables of from,
+	// which are left empty. Readers see either the old or the new tables.
+	Swap(ctx context.Context, name string, from string) (err error)
+}
+
+// MemoryStore keeps rows in process memory.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tables map[string]map[string][]Row
+}
+
+func NewMemoryStore() (obj *MemoryStore) {
+	obj = &MemoryStore{
+		tables: make(map[string]map[string][]Row),
+	}
+	return obj
+}
+
+// Apply is all or nothing: the changes run against copies of the tables they
+// touch, which replace the stored tables only when every change succeeded.
+func (s *MemoryStore) Apply(ctx context.Context, name string, changes []jsruntime.Change) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables := s.tables[name]
+	if tables == nil {
+		tables = make(map[string][]Row)
+		s.tables[name] = tables
+	}
+
+	working := make(map[string][]Row)
+	for _, change := range changes {
+		rows, ok := working[change.Table]
+		if !ok {
+			for _, row := range tables[change.Table] {
+				copied := make(Row, len(row))
+				for column, value := range row {
+					copied[column] = value
+				}
+				rows = append(rows, copied)
+			}
+		}
+		switch change.Operation {
+		case jsruntime.Insert:
+			for _, row := range rows {
+				if len(change.Where) > 0 && matches(row, change.Where) {
+					return fmt.Errorf("%w: %s %v", jsruntime.ErrDuplicateKey, change.Table, change.Where)
+				}
+			}
+			row := Row{}
+			for column, value := range change.Row {
+				row[column] = value
+			}
+			rows = append(rows, row)
+		case jsruntime.Update:
+			for _, row := range rows {
+				if matches(row, change.Where) {
+					for column, value := range change.Row {
+						row[column] = value
+					}
+				}
+			}
+		case jsruntime.Upsert:
+			found := false
+			for _, row := range rows {
+				if matches(row, change.Where) {
+					found = true
+					for column, value := range change.Row {
+						row[column] = value
+					}
+				}
+			}
+			if !found {
+				row := Row{}
+				for column, value := range change.Row {
+					row[column] = value
+				}
+				rows = append(rows, row)
+			}
+		case jsruntime.Delete:
+			kept := rows[:0]
+			for _, row := range rows {
+				if !matches(row, change.Where) {
+					kept = append(kept, row)
+				}
+			}
+			rows = kept
+		default:
+			return fmt.Errorf("unknown operation %s", change.Operation)
+		}
+		err = unique(change.Table, rows, change.Unique)
+		if err != nil {
+			return
+		}
+		working[change.Table] = rows
+	}
+
+	for table, rows := range working {
+		tables[table] = rows
+	}
+	return
+}
+
+func (s *MemoryStore) Rows(ctx context.Context, name string, table string) (rows []Row, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rows = []Row{}
+	for _, row := range s.tables[name][table] {
+		copied := Row{}
+		for column, value := range row {
+			copied[column] = value
+		}
+		rows = append(rows, copied)
+	}
+	return
+}
+
+func (s *MemoryStore) Drop(ctx context.Context, name string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tables, name)
+	return
+}
+
+func (s *MemoryStore) Swap(ctx context.Context, name string, from string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tables[name] = s.tables[from]
+	delete(s.tables, from)
+	return
+}
+
+// unique fails with ErrDuplicateIndex when two rows hold the same values in
+// the columns of one of indexes. Like NULL in SQL, a row missing one of the
+// columns never collides.
+func unique(table string, rows []Row, indexes map[string][]string) error {
+	for name, columns := range indexes {
+		var keys []map[string]interface{}
+		for _, row := range rows {
+			key := make(map[string]interface{})
+			for _, column := range columns {
+				if value, ok := row[column]; ok && value != nil {
+					key[column] = value
+				}
+			}
+			if len(key) < len(columns) {
+				continue
+			}
+			for _, other := range keys {
+				if matches(other, key) {
+					return fmt.Errorf("%w: %s.%s %v", jsruntime.ErrDuplicateIndex, table, name, key)
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+	return nil
+}
+
+func matches(row Row, where map[string]interface{}) bool {
+	for column, value := range where {
+		if !equal(row[column], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares values the way javascript sees them, so 2 and 2.0 are the
+// same number whatever Go type they ended up in.
+func equal(a interface{}, b interface{}) bool {
+	na, aok := number(a)
+	nb, bok := number(b)
+	if aok && bok {
+		return na == nb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func number(value interface{}) (n float64, ok bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
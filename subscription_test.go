@@ -0,0 +1,88 @@
+package esui_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionCatchUp(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	checkpoints := eventstore.NewMemoryCheckpoints()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("abc123").Once()
+	entityID, err := es.CreateEntity(ctx, "user")
+	require.NoError(t, err)
+	idgenerator.On("Generate").Return("xyz123").Once()
+	_, err = es.CreateProjection(ctx, "projection1")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_created"))
+
+	var handled []string
+	subscription := esui.NewSubscription("codegen", estore, checkpoints, func(ctx context.Context, event esui.EstoreEvent) error {
+		handled = append(handled, event.AggregateName+"/"+event.EventName)
+		return nil
+	}, esui.WithBatchSize(2))
+
+	require.NoError(t, subscription.CatchUp(ctx))
+	assert.Equal(t, []string{"entity/created", "projection/created", "entity/event_added"}, handled)
+	position, err := checkpoints.LoadCheckpoint(ctx, "codegen")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, position)
+
+	t.Run("Resume From Checkpoint", func(t *testing.T) {
+		require.NoError(t, es.AddAttribute(ctx, entityID, "user_created", "name", "string"))
+		handled = nil
+		require.NoError(t, subscription.CatchUp(ctx))
+		assert.Equal(t, []string{"entity/attribute_added"}, handled)
+	})
+
+	t.Run("Handler Error Keeps Checkpoint", func(t *testing.T) {
+		require.NoError(t, es.AddEventToEntity(ctx, entityID, "user_deleted"))
+		failing := esui.NewSubscription("codegen", estore, checkpoints, func(ctx context.Context, event esui.EstoreEvent) error {
+			return errors.New("handler failed")
+		})
+		require.Error(t, failing.CatchUp(ctx))
+		position, err := checkpoints.LoadCheckpoint(ctx, "codegen")
+		require.NoError(t, err)
+		assert.EqualValues(t, 4, position)
+	})
+}
+
+func TestSubscriptionLive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	estore := eventstore.NewMemory()
+
+	received := make(chan esui.EstoreEvent)
+	subscription := esui.NewSubscription("live", estore, eventstore.NewMemoryCheckpoints(), func(ctx context.Context, event esui.EstoreEvent) error {
+		received <- event
+		return nil
+	}, esui.WithPollInterval(time.Hour))
+
+	done := make(chan error)
+	go func() {
+		done <- subscription.Run(ctx)
+	}()
+
+	require.NoError(t, estore.StoreEvent(ctx, "abc123", "entity", "attribute_added", esui.EsuiAttributeAdded{Name: "name"}, esui.AnyVersion))
+	select {
+	case event := <-received:
+		assert.Equal(t, "attribute_added", event.EventName)
+		assert.EqualValues(t, 1, event.Position)
+	case <-ctx.Done():
+		t.Fatal("live event not received")
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
@@ -0,0 +1,112 @@
+package esui_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableKeys(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "order_list")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "orders"))
+
+	assert.ErrorIs(t, es.AddColumn(ctx, projectionID, "orders", "id", "varchar"), esui.ErrInvalidColumnType)
+	assert.ErrorIs(t, es.AddColumn(ctx, projectionID, "orders", "id", ""), esui.ErrInvalidColumnType)
+	require.NoError(t, es.AddColumn(ctx, projectionID, "orders", "id", "string"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "orders", "customer_id", "string"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "orders", "total", "decimal"))
+
+	t.Run("Primary Key", func(t *testing.T) {
+		assert.ErrorIs(t, es.SetPrimaryKey(ctx, projectionID, "orders"), esui.ErrInvalidKey)
+		assert.ErrorIs(t, es.SetPrimaryKey(ctx, projectionID, "orders", "id", "id"), esui.ErrInvalidKey)
+		assert.ErrorIs(t, es.SetPrimaryKey(ctx, projectionID, "orders", "number"), esui.ErrColumnNotFound)
+		assert.ErrorIs(t, es.SetPrimaryKey(ctx, projectionID, "customers", "id"), esui.ErrTableNotFound)
+		require.NoError(t, es.SetPrimaryKey(ctx, projectionID, "orders", "id"))
+	})
+
+	t.Run("Indexes", func(t *testing.T) {
+		byCustomer := esui.EsuiIndex{Name: "by_customer", Columns: []string{"customer_id", "total"}}
+		require.NoError(t, es.AddIndex(ctx, projectionID, "orders", byCustomer))
+		assert.ErrorIs(t, es.AddIndex(ctx, projectionID, "orders", byCustomer), esui.ErrIndexExists)
+		assert.ErrorIs(t, es.AddIndex(ctx, projectionID, "orders", esui.EsuiIndex{Columns: []string{"id"}}), esui.ErrEmptyName)
+		assert.ErrorIs(t, es.AddIndex(ctx, projectionID, "orders", esui.EsuiIndex{Name: "by_date", Columns: []string{"date"}}), esui.ErrColumnNotFound)
+		require.NoError(t, es.AddIndex(ctx, projectionID, "orders", esui.EsuiIndex{Name: "by_total", Columns: []string{"total"}}))
+		require.NoError(t, es.RemoveIndex(ctx, projectionID, "orders", "by_total"))
+		assert.ErrorIs(t, es.RemoveIndex(ctx, projectionID, "orders", "by_total"), esui.ErrIndexNotFound)
+	})
+
+	projection, err := es.GetProjection(ctx, projectionID)
+	require.NoError(t, err)
+	orders := projection.Tables["orders"]
+	assert.Equal(t, esui.ColumnDecimal, orders.Columns["total"].Type)
+	assert.Equal(t, []string{"id"}, orders.PrimaryKey)
+	assert.Equal(t, map[string]esui.EsuiIndex{
+		"by_customer": {Name: "by_customer", Columns: []string{"customer_id", "total"}},
+	}, orders.Indexes)
+}
+
+func TestTableChanges(t *testing.T) {
+	ctx := context.TODO()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(eventstore.NewMemory(), idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "order_list")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "ordrs"))
+	require.NoError(t, es.CreateTable(ctx, projectionID, "drafts"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "ordrs", "order_id", "string"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "ordrs", "total", "int"))
+	require.NoError(t, es.AddColumn(ctx, projectionID, "ordrs", "note", "string"))
+	require.NoError(t, es.SetPrimaryKey(ctx, projectionID, "ordrs", "order_id"))
+
+	t.Run("Apply Changes", func(t *testing.T) {
+		require.NoError(t, es.DropTable(ctx, projectionID, "drafts"))
+		require.NoError(t, es.RenameTable(ctx, projectionID, "ordrs", "orders"))
+		require.NoError(t, es.RenameColumn(ctx, projectionID, "orders", "order_id", "id"))
+		require.NoError(t, es.ChangeColumnType(ctx, projectionID, "orders", "total", esui.ColumnDecimal))
+		require.NoError(t, es.DropColumn(ctx, projectionID, "orders", "note"))
+
+		projection, err := es.GetProjection(ctx, projectionID)
+		require.NoError(t, err)
+		require.Equal(t, []string{"orders"}, tableNames(projection))
+		orders := projection.Tables["orders"]
+		assert.Equal(t, "orders", orders.Name)
+		assert.Equal(t, map[string]esui.EsuiColumn{
+			"id":    {Name: "id", Type: esui.ColumnString},
+			"total": {Name: "total", Type: esui.ColumnDecimal},
+		}, orders.Columns)
+		assert.Equal(t, []string{"id"}, orders.PrimaryKey)
+	})
+
+	t.Run("Guards", func(t *testing.T) {
+		assert.ErrorIs(t, es.CreateTable(ctx, projectionID, "orders"), esui.ErrTableExists)
+		assert.ErrorIs(t, es.DropTable(ctx, projectionID, "drafts"), esui.ErrTableNotFound)
+		require.NoError(t, es.CreateTable(ctx, projectionID, "drafts"))
+		assert.ErrorIs(t, es.RenameTable(ctx, projectionID, "drafts", "orders"), esui.ErrTableExists)
+		assert.ErrorIs(t, es.RenameTable(ctx, projectionID, "drafts", ""), esui.ErrEmptyName)
+		assert.ErrorIs(t, es.DropColumn(ctx, projectionID, "orders", "id"), esui.ErrColumnInUse)
+		assert.ErrorIs(t, es.DropColumn(ctx, projectionID, "orders", "note"), esui.ErrColumnNotFound)
+		assert.ErrorIs(t, es.RenameColumn(ctx, projectionID, "orders", "total", "id"), esui.ErrColumnExists)
+		assert.ErrorIs(t, es.ChangeColumnType(ctx, projectionID, "orders", "total", "money"), esui.ErrInvalidColumnType)
+	})
+}
+
+func tableNames(projection esui.EsuiProjection) (names []string) {
+	for name := range projection.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
@@ -0,0 +1,80 @@
+package esui_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ariefsam/esui"
+	"github.com/ariefsam/esui/eventstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEntityAt(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("prod1").Once()
+	entityID, err := es.CreateEntity(ctx, "product")
+	require.NoError(t, err)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_created"))
+	time.Sleep(time.Millisecond)
+	between := time.Now()
+	time.Sleep(time.Millisecond)
+	require.NoError(t, es.AddEventToEntity(ctx, entityID, "product_deleted"))
+
+	events, err := estore.FetchAggregateEvents(ctx, string(entityID), "entity", "")
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.False(t, events[0].Timestamp.IsZero())
+
+	t.Run("At Event", func(t *testing.T) {
+		entity, err := es.GetEntityAt(ctx, entityID, esui.AtEvent(events[0].EventID))
+		require.NoError(t, err)
+		assert.Equal(t, "product", entity.Name)
+		assert.Empty(t, entity.Events)
+		assert.EqualValues(t, 1, entity.Version)
+
+		_, err = es.GetEntityAt(ctx, entityID, esui.AtEvent("unknown"))
+		assert.ErrorIs(t, err, esui.ErrEventNotFound)
+	})
+
+	t.Run("At Time", func(t *testing.T) {
+		entity, err := es.GetEntityAt(ctx, entityID, esui.AtTime(between))
+		require.NoError(t, err)
+		assert.Contains(t, entity.Events, "product_created")
+		assert.NotContains(t, entity.Events, "product_deleted")
+
+		entity, err = es.GetEntityAt(ctx, entityID, esui.AtTime(events[0].Timestamp.Add(-time.Second)))
+		require.NoError(t, err)
+		assert.Empty(t, entity.Name)
+
+		entity, err = es.GetEntityAt(ctx, entityID, esui.PointInTime{})
+		require.NoError(t, err)
+		assert.Len(t, entity.Events, 2)
+	})
+}
+
+func TestGetProjectionAt(t *testing.T) {
+	ctx := context.TODO()
+	estore := eventstore.NewMemory()
+	idgenerator := &mockIDGenerator{}
+	es := esui.NewEsui(estore, idgenerator)
+
+	idgenerator.On("Generate").Return("proj1").Once()
+	projectionID, err := es.CreateProjection(ctx, "product_list")
+	require.NoError(t, err)
+	require.NoError(t, es.CreateTable(ctx, projectionID, "products"))
+	require.NoError(t, es.RenameTable(ctx, projectionID, "products", "items"))
+
+	events, err := estore.FetchAggregateEvents(ctx, string(projectionID), "projection", "")
+	require.NoError(t, err)
+	projection, err := es.GetProjectionAt(ctx, projectionID, esui.AtEvent(events[1].EventID))
+	require.NoError(t, err)
+	assert.Contains(t, projection.Tables, "products")
+	assert.NotContains(t, projection.Tables, "items")
+	assert.EqualValues(t, 2, projection.Version)
+}